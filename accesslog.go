@@ -0,0 +1,76 @@
+package scroll
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const commonLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// CommonLogFormatRequestLogger returns a request logger, assignable to LogRequest and Spec.LogRequest,
+// that writes a line to w for every request in the Apache Common Log Format, e.g.
+//
+//  203.0.113.7 - bob [10/Jun/2018:13:55:36 +0000] "GET /messages?limit=10 HTTP/1.1" 200 2326
+//
+// The client IP is determined with ClientIP, the user is taken from the request's basic auth
+// credentials and the size of the response, before compression, with ResponseSizeFromContext.
+// Messages logged without a request, e.g. failures to marshal a response, are skipped.
+func CommonLogFormatRequestLogger(w io.Writer) func(*http.Request, int, time.Duration, error) {
+	return accessLogger(w, false)
+}
+
+// CombinedLogFormatRequestLogger returns a request logger just like CommonLogFormatRequestLogger that
+// writes lines in the Apache Combined Log Format, i.e. followed by the `Referer` and `User-Agent` headers.
+func CombinedLogFormatRequestLogger(w io.Writer) func(*http.Request, int, time.Duration, error) {
+	return accessLogger(w, true)
+}
+
+func accessLogger(w io.Writer, combined bool) func(*http.Request, int, time.Duration, error) {
+	var mutex sync.Mutex
+	return func(r *http.Request, status int, elapsedTime time.Duration, err error) {
+		if r == nil {
+			return
+		}
+		line := commonLogLine(r, status, time.Now().Add(-elapsedTime))
+		if combined {
+			line += fmt.Sprintf(` "%s" "%s"`, escapeLogField(r.Referer()), escapeLogField(r.UserAgent()))
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		io.WriteString(w, line+"\n")
+	}
+}
+
+// commonLogLine formats a request in the Common Log Format, start is the time the request was received.
+func commonLogLine(r *http.Request, status int, start time.Time) string {
+	user := "-"
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		// The user is not quoted, hence spaces would break the line apart.
+		user = strings.Replace(escapeLogField(username), " ", "%20", -1)
+	}
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+	size := "-"
+	if n := ResponseSizeFromContext(r.Context()); n > 0 {
+		size = strconv.FormatInt(n, 10)
+	}
+	return fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s`, ClientIP(r), user, start.Format(commonLogTimeFormat),
+		escapeLogField(r.Method), escapeLogField(uri), escapeLogField(r.Proto), status, size)
+}
+
+var logFieldEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
+// escapeLogField escapes the characters of a value that would break the log line apart.
+func escapeLogField(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return logFieldEscaper.Replace(value)
+}
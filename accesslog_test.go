@@ -0,0 +1,62 @@
+package scroll
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type AccessLogSuite struct{}
+
+var _ = Suite(&AccessLogSuite{})
+
+const commonLogPattern = `\d{1,3}(\.\d{1,3}){3} - \S+ \[\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "[A-Z]+ \S+ HTTP/\d\.\d" \d{3} (\d+|-)`
+
+func (s *AccessLogSuite) TestCommonLogFormat(c *C) {
+	var buf bytes.Buffer
+	logRequest := CommonLogFormatRequestLogger(&buf)
+	r := httptest.NewRequest("GET", "/messages?limit=10", nil)
+	r.RemoteAddr = "203.0.113.7:43210"
+	r.SetBasicAuth("bob", "secret")
+
+	logRequest(r, http.StatusOK, time.Millisecond, nil)
+	logRequest(nil, http.StatusInternalServerError, time.Nanosecond, nil)
+
+	c.Assert(buf.String(), Matches, commonLogPattern+"\n")
+	c.Assert(buf.String(), Matches, `203\.0\.113\.7 - bob \[.*\] "GET /messages\?limit=10 HTTP/1\.1" 200 -\n`)
+}
+
+func (s *AccessLogSuite) TestCombinedLogFormat(c *C) {
+	var buf bytes.Buffer
+	logRequest := CombinedLogFormatRequestLogger(&buf)
+	r := httptest.NewRequest("POST", "/messages", nil)
+	r.RemoteAddr = "203.0.113.7:43210"
+	r.Header.Set("User-Agent", `curl/7.58.0 "quoted"`)
+
+	logRequest(r, http.StatusCreated, time.Millisecond, nil)
+
+	c.Assert(buf.String(), Matches, commonLogPattern+` "-" "curl/7\.58\.0 \\"quoted\\""`+"\n")
+}
+
+func (s *AccessLogSuite) TestCommonLogFormatApp(c *C) {
+	var buf bytes.Buffer
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Methods:    []string{"GET"},
+		Paths:      []string{"/messages/{id}"},
+		LogRequest: CommonLogFormatRequestLogger(&buf),
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return nil, NotFoundError{Description: "message"}
+		},
+	})
+	c.Assert(err, IsNil)
+
+	app.GetHandler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/messages/1", nil))
+
+	c.Assert(buf.String(), Matches, commonLogPattern+"\n")
+	c.Assert(buf.String(), Matches, `.* "GET /messages/1 HTTP/1\.1" 404 [1-9][0-9]*\n`)
+}
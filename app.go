@@ -2,10 +2,15 @@ package scroll
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -19,13 +24,21 @@ import (
 
 // Represents an app.
 type App struct {
-	once       *sync.Once
-	Config     AppConfig
-	router     *mux.Router
-	stats      *appStats
-	vulcandReg *vulcand.Registry
-	done       chan struct{}
-	wg         sync.WaitGroup
+	once         *sync.Once
+	Config       AppConfig
+	router       *mux.Router
+	encodedPath  bool
+	middlewares  []func(http.Handler) http.Handler
+	handler      http.Handler
+	stats        *appStats
+	vulcandReg   *vulcand.Registry
+	httpSrv      *http.Server
+	healthChecks healthChecks
+	started      time.Time
+	specs        []Spec
+	routes       map[string]bool
+	done         chan struct{}
+	wg           sync.WaitGroup
 }
 
 // This is a separate struct because JSON unmarshal() throws errors
@@ -49,26 +62,141 @@ type AppConfig struct {
 	ListenIP   string
 	ListenPort int
 
+	// network the app will listen on, either "tcp" (default) or "unix". When "unix"
+	// is used the app binds to a socket at ListenPath instead of ListenIP/ListenPort,
+	// the socket file is removed when the app is shut down.
+	ListenNetwork string
+	ListenPath    string
+
 	// optional router to use
 	Router *mux.Router
 
-	// host names of the public and protected API entrypoints used for vulcand registration
+	// if true, a request whose path differs from a registered path only by a trailing slash, e.g.
+	// "/users/" for "/users", is redirected to the registered path with 301 Moved Permanently. Note
+	// that clients typically follow a 301 redirect with a GET request without a body, so a POST
+	// request to such a path loses its body.
+	StrictSlash bool
+
+	// optional path of the health check endpoint, e.g. "/healthz". The endpoint
+	// responds with 200 OK unless one of the checks registered with
+	// App.RegisterHealthCheck fails.
+	HealthCheckPath string
+
+	// version of the app, e.g. a build SHA injected at build time with
+	// -ldflags "-X main.version=...", reported by the info endpoint
+	Version string
+
+	// optional path of the info endpoint, e.g. "/_info". The endpoint responds
+	// with the app's name, version, uptime and Go runtime version.
+	InfoPath string
+
+	// host names of the public and protected API entrypoints used for vulcand registration: handlers
+	// are routed via the host of their scope, see Spec.Scope. ProtectedAPIHost is required to register
+	// protected handlers.
 	PublicAPIHost    string
 	PublicAPIURL     string // NOT USED, included for completeness
 	ProtectedAPIHost string
 	ProtectedAPIURL  string
 
-	// Vulcand config must be provided to enable registration in etcd.
+	// Vulcand config used to register the app in etcd, a default config is used if not provided.
 	Vulcand *vulcand.Config
 
+	// if true, the app is not registered in vulcand, e.g. when it listens on a unix socket or is not
+	// run behind vulcand
+	DisableRegistration bool
+
 	// metrics service used for emitting the app's real-time metrics
 	Client metrics.Client
 
+	// optional prefix of the metrics emitted with Client, e.g. "myservice.", so that the metrics of
+	// services reporting to the same backend do not collide
+	MetricsPrefix string
+
+	// optional path of an endpoint exposing the app's request stats in the
+	// Prometheus text format, e.g. "/metrics"
+	MetricsPath string
+
+	// optional CORS configuration, when provided preflight requests are answered for every
+	// registered path and CORS headers are added to responses to allowed origins
+	CORS *CORSConfig
+
+	// optional structured logger, when provided requests are logged with it as key/value
+	// pairs instead of the textual LogRequest line
+	Logger StructuredLogger
+
+	// optional hook that wraps the responses of the app's handlers before they are marshalled, e.g. to
+	// standardize an envelope like {"data": ..., "meta": ...}; error responses are passed to it as well,
+	// along with their status. If not provided, responses are replied as they are.
+	ResponseWrapper func(status int, body interface{}) interface{}
+
+	// if true, the responses of the app's handlers are encoded without escaping the HTML characters
+	// <, > and &, which are escaped by default so that responses can be safely embedded in HTML.
+	// Responses are then encoded with encoding/json, hence it cannot be combined with a custom Marshaler.
+	DisableHTMLEscaping bool
+
+	// store of the responses of handlers with Spec.IdempotencyTTL set, an in-memory store is used
+	// if not provided
+	IdempotencyStore IdempotencyStore
+
+	// returns the caller a request was made by, e.g. the account it authenticates as, so that the
+	// idempotency keys of different callers don't collide; if not provided, requests are told apart
+	// by the username of their basic auth credentials
+	IdempotencyScope func(*http.Request) string
+
+	// Timeouts of the app's HTTP server that protect it against slow clients. If not specified,
+	// reads time out after 10 seconds, writes and idle keep-alive connections after 60 seconds.
 	HTTP struct {
 		ReadTimeout  time.Duration
 		WriteTimeout time.Duration
 		IdleTimeout  time.Duration
 	}
+
+	// TLS certificate and key files, when provided the app is served over HTTPS.
+	// If a pool of client CAs is provided as well, clients are required to present
+	// a certificate signed by one of them (mutual TLS).
+	TLS struct {
+		CertFile  string
+		KeyFile   string
+		ClientCAs *x509.CertPool
+	}
+
+	// how long to wait for active requests to complete on shutdown before the
+	// remaining connections are forcibly closed
+	ShutdownTimeout time.Duration
+
+	// signals that trigger a graceful shutdown, SIGINT, SIGQUIT and SIGTERM if not specified.
+	// Note that SIGKILL cannot be caught.
+	ShutdownSignals []os.Signal
+}
+
+// Validate checks that the app can be run with the config, so that problems are reported before the
+// app starts rather than on the first use of the invalid setting, e.g. by vulcand registration.
+func (config AppConfig) Validate() error {
+	switch config.ListenNetwork {
+	case "", "tcp":
+		if config.ListenPort < 0 || config.ListenPort > 65535 {
+			return errors.Errorf("invalid listen port %d: must be between 0 and 65535", config.ListenPort)
+		}
+	case "unix":
+		if config.ListenPath == "" {
+			return errors.New("invalid config: a listen path is required to listen on a unix socket")
+		}
+	default:
+		return errors.Errorf("invalid listen network %q: must be either tcp or unix", config.ListenNetwork)
+	}
+
+	if !config.DisableRegistration {
+		if config.Name == "" {
+			return errors.New("invalid config: a name is required for vulcand registration, it identifies the app's backend")
+		}
+		if config.ListenPort == 0 {
+			return errors.New("invalid config: a listen port is required for vulcand registration, it is a part of the app's server URL")
+		}
+		if config.PublicAPIHost == "" {
+			return errors.New("invalid config: a public API host is required for vulcand registration, public handlers are routed via it")
+		}
+	}
+	return nil
 }
 
 // Create a new app.
@@ -88,20 +216,42 @@ func NewAppWithConfig(config AppConfig) (*App, error) {
 		return nil, errors.Wrap(err, "while fetching etcd config")
 	}
 
-	app := App{Config: config}
-
-	if LogRequest == nil {
-		LogRequest = logRequest
+	if config.CORS != nil {
+		if err := config.CORS.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.DisableHTMLEscaping && customMarshaler() {
+		return nil, errors.New("HTML escaping cannot be disabled when a custom Marshaler is used")
 	}
 
+	app := App{Config: config}
+
 	app.router = config.Router
 	if app.router == nil {
 		app.router = mux.NewRouter()
 		app.router.UseEncodedPath()
+		app.encodedPath = true
+	}
+	if config.StrictSlash {
+		app.router.StrictSlash(true)
+	}
+	if app.router.NotFoundHandler == nil {
+		app.router.NotFoundHandler = http.HandlerFunc(app.handleNotFound)
 	}
+	if app.router.MethodNotAllowedHandler == nil {
+		app.router.MethodNotAllowedHandler = http.HandlerFunc(app.handleMethodNotAllowed)
+	}
+
 	app.router.HandleFunc("/_ping", handlePing).Methods("GET")
+	if config.HealthCheckPath != "" {
+		app.router.HandleFunc(config.HealthCheckPath, app.handleHealthCheck).Methods("GET")
+	}
+	if config.InfoPath != "" {
+		app.router.HandleFunc(config.InfoPath, app.handleInfo).Methods("GET")
+	}
 
-	if config.Vulcand != nil {
+	if !config.DisableRegistration {
 		var err error
 		app.vulcandReg, err = vulcand.NewRegistry(*config.Vulcand, config.Name, config.ListenIP, config.ListenPort)
 		if err != nil {
@@ -109,15 +259,59 @@ func NewAppWithConfig(config AppConfig) (*App, error) {
 		}
 	}
 
-	app.stats = newAppStats(config.Client)
+	app.stats = newAppStats(config.Client, config.MetricsPrefix)
+	if config.MetricsPath != "" {
+		app.stats.prom = newPromCollector()
+		app.router.HandleFunc(config.MetricsPath, app.stats.prom.handleMetrics).Methods("GET")
+	}
 	return &app, nil
 }
 
 // Register a handler function.
 //
+// The handler is registered once for every path listed in the spec. All of
+// the registered paths share the spec's MetricName, so their stats are
+// aggregated together. An error is returned if one of the spec's methods is
+// already registered for one of its paths.
+//
 // If vulcan registration is enabled in the both app config and handler spec,
 // the handler will be registered in the local etcd instance.
 func (app *App) AddHandler(spec Spec) error {
+	_, err := app.addHandler(app.router, "", spec)
+	return err
+}
+
+// AddRoute registers a handler just like AddHandler, in addition returning the registered route, e.g.
+// to build URLs for it.
+func (app *App) AddRoute(spec Spec) (*RegisteredRoute, error) {
+	return app.addHandler(app.router, "", spec)
+}
+
+// addHandler registers a handler with the provided router, which serves paths under prefix.
+func (app *App) addHandler(router *mux.Router, prefix string, spec Spec) (*RegisteredRoute, error) {
+	if len(spec.Headers)%2 != 0 {
+		return nil, fmt.Errorf("the spec headers must be key/value pairs: %v", spec.Headers)
+	}
+	if len(spec.Queries)%2 != 0 {
+		return nil, fmt.Errorf("the spec queries must be key/value pairs: %v", spec.Queries)
+	}
+	for _, path := range spec.Paths {
+		if !strings.HasPrefix(prefix+path, "/") {
+			return nil, fmt.Errorf("the spec paths must start with a slash: %q", path)
+		}
+	}
+	if app.vulcandReg != nil {
+		if _, err := app.apiHostForScope(spec.Scope); err != nil {
+			return nil, err
+		}
+	}
+	keys := routeKeys(prefix, spec)
+	for _, key := range keys {
+		if registered, ok := app.conflictingRoute(key); ok {
+			return nil, fmt.Errorf("the route is already registered: %v", registered)
+		}
+	}
+
 	var handler http.HandlerFunc
 
 	// make a handler depending on the function provided in the spec
@@ -127,29 +321,179 @@ func (app *App) AddHandler(spec Spec) error {
 		handler = MakeHandler(app, spec.Handler, spec)
 	} else if spec.HandlerWithBody != nil {
 		handler = MakeHandlerWithBody(app, spec.HandlerWithBody, spec)
+	} else if spec.HandlerWithContext != nil {
+		handler = MakeHandlerWithContext(app, spec.HandlerWithContext, spec)
 	} else {
-		return fmt.Errorf("the spec does not provide a handler function: %v", spec)
+		return nil, fmt.Errorf("the spec does not provide a handler function: %v", spec)
 	}
 
-	for _, path := range spec.Paths {
-		route := app.router.HandleFunc(path, handler).Methods(spec.Methods...)
+	if app.routes == nil {
+		app.routes = make(map[string]bool)
+	}
+	for _, key := range keys {
+		app.routes[key] = true
+	}
+	registered := spec
+	registered.Paths = make([]string, len(spec.Paths))
+	for i, path := range spec.Paths {
+		registered.Paths[i] = prefix + path
+	}
+	app.specs = append(app.specs, registered)
+
+	if spec.IdempotencyTTL > 0 {
+		// Replies that do not reach the handler are logged and tracked just like its own, raw handlers
+		// are neither.
+		var track requestTracker
+		if spec.RawHandler == nil {
+			track = func(r *http.Request, status int, elapsedTime time.Duration, err error, written int64) {
+				app.trackRequest(r, spec, status, elapsedTime, err, written)
+			}
+		}
+		handler = idempotencyHandler(app.Config.IdempotencyStore, app.Config.IdempotencyScope, spec.IdempotencyTTL, track, handler)
+	}
+	if spec.Compress {
+		minSize := spec.CompressMinSize
+		if minSize == 0 {
+			minSize = DefaultCompressMinSize
+		}
+		handler = compressHandler(handler, minSize)
+	}
+	if app.Config.CORS != nil {
+		handler = corsHandler(app.Config.CORS, handler)
+	}
+	// HEAD is only answered locally, vulcand frontends and CORS preflight responses (HEAD is a
+	// CORS-safelisted method) are kept to the methods of the spec.
+	methods := spec.Methods
+	if !spec.DisableAutoHead {
+		methods = withHead(spec.Methods)
+		handler = headHandler(handler)
+	}
+
+	registeredRoute := &RegisteredRoute{Spec: registered}
+	for i, path := range spec.Paths {
+		route := router.HandleFunc(path, handler)
+		if len(methods) != 0 {
+			route.Methods(methods...)
+		}
+		registeredRoute.routes = append(registeredRoute.routes, route)
 		if len(spec.Headers) != 0 {
 			route.Headers(spec.Headers...)
 		}
+		if len(spec.Queries) != 0 {
+			route.Queries(spec.Queries...)
+		}
+		if spec.Name != "" && i == 0 {
+			route.Name(spec.Name)
+		}
+		if app.Config.CORS != nil {
+			router.HandleFunc(path, corsPreflightHandler(app.Config.CORS, spec.Methods)).Methods("OPTIONS")
+		}
 		if app.vulcandReg != nil {
-			app.registerFrontend(spec.Methods, path, spec.Scope, spec.Middlewares)
+			if err := app.registerFrontend(prefix+path, spec); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	return nil
+	return registeredRoute, nil
+}
+
+// conflictingRoute returns the key of the registered route that the route with the provided key
+// conflicts with: a route for the same method and path, or a route for any method on the same path if
+// either of them is for any method.
+func (app *App) conflictingRoute(key string) (string, bool) {
+	if app.routes[key] {
+		return key, true
+	}
+	method, route := splitRouteKey(key)
+	for registered := range app.routes {
+		registeredMethod, registeredRoute := splitRouteKey(registered)
+		if registeredRoute == route && (method == "*" || registeredMethod == "*") {
+			return registered, true
+		}
+	}
+	return "", false
+}
+
+func splitRouteKey(key string) (method, route string) {
+	parts := strings.SplitN(key, " ", 2)
+	return parts[0], parts[1]
+}
+
+// routeKeys returns keys identifying the routes registered for the spec, one per method and path.
+func routeKeys(prefix string, spec Spec) []string {
+	var keys []string
+	methods := spec.Methods
+	if len(methods) == 0 {
+		methods = []string{"*"}
+	}
+	for _, path := range spec.Paths {
+		for _, method := range methods {
+			// Methods are matched case-insensitively by the router.
+			key := strings.ToUpper(method) + " " + prefix + path
+			if len(spec.Headers) != 0 {
+				key += fmt.Sprintf(" headers=%v", spec.Headers)
+			}
+			if len(spec.Queries) != 0 {
+				key += fmt.Sprintf(" queries=%v", spec.Queries)
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Specs returns the specs of the handlers added to the app, in the order they were added, e.g. to
+// document the app's routes. The paths of handlers added to a group include the group's prefix.
+func (app *App) Specs() []Spec {
+	specs := make([]Spec, len(app.specs))
+	copy(specs, app.specs)
+	return specs
+}
+
+// Use adds an in-process middleware to the app's handler chain.
+//
+// Middlewares wrap the whole router, hence they apply to every request handled by the app, no
+// matter whether the handlers were added before or after the middleware. A middleware added earlier
+// is executed first, i.e. it wraps all the middlewares added after it.
+func (app *App) Use(mw func(http.Handler) http.Handler) {
+	app.middlewares = append(app.middlewares, mw)
+
+	var handler http.Handler = app.router
+	for i := len(app.middlewares) - 1; i >= 0; i-- {
+		handler = app.middlewares[i](handler)
+	}
+	app.handler = handler
 }
 
-// GetHandler returns HTTP compatible Handler interface.
+// GetHandler returns HTTP compatible Handler interface, the router wrapped with the middlewares
+// added with Use.
 func (app *App) GetHandler() http.Handler {
+	if app.handler == nil {
+		return app.router
+	}
+	return app.handler
+}
+
+// Router returns the app's router, e.g. to serve websockets or static files that do not fit the
+// Spec model. Note that handlers registered directly with the router bypass scroll: their requests
+// are neither logged nor tracked in stats, and they are not registered with vulcand.
+func (app *App) Router() *mux.Router {
 	return app.router
 }
 
+// URL builds a URL for the route registered with the provided name, pairs are the route's path
+// variables as key/value pairs, e.g. URL("domain", "name", "example.com").
+func (app *App) URL(name string, pairs ...string) (*url.URL, error) {
+	route := app.router.Get(name)
+	if route == nil {
+		return nil, fmt.Errorf("no route named %q", name)
+	}
+	return route.URL(pairs...)
+}
+
 // SetNotFoundHandler sets the handler for the case when URL can not be matched by the router.
+// By default a JSON 404 response is returned.
 func (app *App) SetNotFoundHandler(fn http.HandlerFunc) {
 	app.router.NotFoundHandler = fn
 }
@@ -161,59 +505,85 @@ func (app *App) IsPublicRequest(request *http.Request) bool {
 
 // Start the app on the configured host/port.
 //
+// The app's router is served by a dedicated http.Server rather than the global
+// http.DefaultServeMux, so several apps can run in the same process.
+//
 // Supports graceful shutdown on 'kill' and 'int' signals.
 func (app *App) Run() error {
+	if err := app.Config.Validate(); err != nil {
+		return err
+	}
+	addr := fmt.Sprintf("%v:%v", app.Config.ListenIP, app.Config.ListenPort)
+	if app.Config.ListenNetwork == "unix" {
+		addr = app.Config.ListenPath
+	}
+	listener, err := net.Listen(app.Config.ListenNetwork, addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on %s", addr)
+	}
+	return app.RunWithListener(listener)
+}
+
+// RunWithListener starts the app serving requests on the provided listener, just like Run.
+//
+// The listener is closed when the app is shut down.
+func (app *App) RunWithListener(listener net.Listener) error {
+	app.done = make(chan struct{})
+	app.once = &sync.Once{}
+
 	if app.vulcandReg != nil {
 		err := app.vulcandReg.Start()
 		if err != nil {
+			listener.Close()
 			return fmt.Errorf("failed to start vulcand registry: err=(%s)", err)
 		}
 		heartbeatCh := make(chan os.Signal, 1)
 		signal.Notify(heartbeatCh, syscall.SIGUSR1)
+		app.wg.Add(1)
 		go func() {
-			sig := <-heartbeatCh
-			log.Infof("Got signal %v, canceling vulcand registration", sig)
-			app.vulcandReg.Stop()
+			defer app.wg.Done()
+			defer signal.Stop(heartbeatCh)
+			select {
+			case sig := <-heartbeatCh:
+				log.Infof("Got signal %v, canceling vulcand registration", sig)
+				app.vulcandReg.Stop()
+			case <-app.done:
+			}
 		}()
 	}
 
-	addr := fmt.Sprintf("%v:%v", app.Config.ListenIP, app.Config.ListenPort)
-	httpSrv := &http.Server{
-		Addr:         addr,
-		ReadTimeout:  app.Config.HTTP.ReadTimeout,
-		WriteTimeout: app.Config.HTTP.WriteTimeout,
-		IdleTimeout:  app.Config.HTTP.IdleTimeout,
-		Handler:      app.router,
-	}
+	var err error
+	app.httpSrv = app.newHTTPServer(listener.Addr().String())
+	app.started = time.Now()
 
 	// listen for a shutdown signal
-	app.done = make(chan struct{})
-	app.once = &sync.Once{}
 	signalCh := make(chan os.Signal, 1)
-	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	signal.Notify(signalCh, app.Config.ShutdownSignals...)
 
 	// Start a stop signal waiting goroutine.
 	app.wg.Add(1)
 	go func() {
 		defer app.wg.Done()
+		defer signal.Stop(signalCh)
 		select {
 		case s := <-signalCh:
 			log.Infof("Got signal %v, shutting down", s)
 		case <-app.done:
 		}
-		if app.vulcandReg != nil {
-			app.vulcandReg.Stop()
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), app.Config.ShutdownTimeout)
 		defer cancel()
-		if err := httpSrv.Shutdown(ctx); err != nil {
+		if err := app.Shutdown(ctx); err != nil {
 			log.Errorf("Failed to shutdown HTTP server: err=%v", err)
 		}
 	}()
-	err := httpSrv.ListenAndServe()
+	if app.Config.TLS.CertFile != "" || app.Config.TLS.KeyFile != "" {
+		err = app.httpSrv.ServeTLS(listener, app.Config.TLS.CertFile, app.Config.TLS.KeyFile)
+	} else {
+		err = app.httpSrv.Serve(listener)
+	}
 
 	// In case the HTTP server failed to start we need to stop the signal
-	// waiting goroutine. But it would not hurt to close the channel, even if
+	// waiting goroutines. But it would not hurt to close the channel, even if
 	// the HTTP server was terminated from the signal waiting goroutine.
 	app.Stop()
 
@@ -222,6 +592,46 @@ func (app *App) Run() error {
 	return err
 }
 
+// Shutdown gracefully shuts down the running app: it cancels the vulcand registration, stops
+// accepting new connections and waits for the active requests to complete. If the context expires
+// before that, the remaining connections are forcibly closed and the context's error is returned.
+//
+// Once Shutdown is called, Run returns http.ErrServerClosed.
+func (app *App) Shutdown(ctx context.Context) error {
+	if app.httpSrv == nil {
+		return nil
+	}
+	if app.vulcandReg != nil {
+		app.vulcandReg.Stop()
+	}
+	err := app.httpSrv.Shutdown(ctx)
+	if err != nil {
+		app.httpSrv.Close()
+	}
+	return err
+}
+
+// newHTTPServer creates the server the app is run with, configured with the app's timeouts to protect
+// against slow clients.
+func (app *App) newHTTPServer(addr string) *http.Server {
+	srv := &http.Server{
+		Addr:         addr,
+		ReadTimeout:  app.Config.HTTP.ReadTimeout,
+		WriteTimeout: app.Config.HTTP.WriteTimeout,
+		IdleTimeout:  app.Config.HTTP.IdleTimeout,
+		Handler:      app.GetHandler(),
+	}
+	if app.Config.TLS.ClientCAs != nil {
+		srv.TLSConfig = &tls.Config{
+			ClientCAs:  app.Config.TLS.ClientCAs,
+			ClientAuth: tls.RequireAndVerifyClientCert,
+		}
+	}
+	return srv
+}
+
+// Stop shuts down the running app waiting up to the configured ShutdownTimeout for
+// the active requests to complete.
 func (app *App) Stop() {
 	if app.once != nil {
 		app.once.Do(func() { close(app.done) })
@@ -229,27 +639,40 @@ func (app *App) Stop() {
 	app.wg.Wait()
 }
 
-// registerLocation is a helper for registering handlers in vulcan.
-func (app *App) registerFrontend(methods []string, path string, scope Scope, middlewares []vulcand.Middleware) error {
-	host, err := app.apiHostForScope(scope)
+// registerFrontend is a helper for registering handlers in vulcan.
+func (app *App) registerFrontend(path string, spec Spec) error {
+	host, err := app.apiHostForScope(spec.Scope)
 	if err != nil {
 		return err
 	}
-	app.vulcandReg.AddFrontend(host, path, methods, middlewares)
-	return nil
+	return app.vulcandReg.AddFrontendWithOptions(host, path, spec.Methods, spec.Middlewares,
+		vulcand.FrontendOptions{FailoverPredicate: spec.FailoverPredicate})
 }
 
 // apiHostForScope is a helper that returns an appropriate API hostname for a provided scope.
+//
+// A protected handler is only reachable via the protected API host, hence it cannot be registered
+// unless one is configured: a frontend without a host would route requests from any host to it.
 func (app *App) apiHostForScope(scope Scope) (string, error) {
 	if scope == ScopePublic {
 		return app.Config.PublicAPIHost, nil
 	} else if scope == ScopeProtected {
+		if app.Config.ProtectedAPIHost == "" {
+			return "", errors.New("protected handlers require a protected API host to be configured")
+		}
 		return app.Config.ProtectedAPIHost, nil
 	} else {
 		return "", fmt.Errorf("unknown scope value: %v", scope)
 	}
 }
 
+// handleNotFound replies with a JSON 404 to requests that do not match any route.
+func (app *App) handleNotFound(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	Reply(w, Response{ErrorMessageKey: "not found", "code": "NOT_FOUND"}, http.StatusNotFound)
+	app.stats.TrackRequest(notFoundMetricName, http.StatusNotFound, time.Since(start))
+}
+
 func handlePing(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	w.WriteHeader(http.StatusOK)
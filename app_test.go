@@ -0,0 +1,784 @@
+package scroll
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	etcd "github.com/coreos/etcd/clientv3"
+	"github.com/mailgun/scroll/vulcand"
+	. "gopkg.in/check.v1"
+)
+
+type AppSuite struct{}
+
+var _ = Suite(&AppSuite{})
+
+// newRunnableApp creates an app listening on a free local port. Vulcand registration is disabled
+// since it requires etcd.
+func newRunnableApp(c *C) *App {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	port := l.Addr().(*net.TCPAddr).Port
+	c.Assert(l.Close(), IsNil)
+
+	app, err := NewAppWithConfig(AppConfig{Name: "test", ListenIP: "127.0.0.1", ListenPort: port, DisableRegistration: true})
+	c.Assert(err, IsNil)
+	return app
+}
+
+// waitForPing waits for the app's ping endpoint to start responding.
+func waitForPing(c *C, app *App) {
+	waitForURL(c, http.DefaultClient, fmt.Sprintf("http://%s:%d/_ping", app.Config.ListenIP, app.Config.ListenPort))
+}
+
+// waitForURL waits for the URL to start responding with 200 OK.
+func waitForURL(c *C, client *http.Client, url string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		response, err := client.Get(url)
+		if err == nil {
+			response.Body.Close()
+			c.Assert(response.StatusCode, Equals, http.StatusOK)
+			return
+		}
+		if time.Now().After(deadline) {
+			c.Fatalf("app did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (s *AppSuite) TestRunTwoApps(c *C) {
+	apps := []*App{newRunnableApp(c), newRunnableApp(c)}
+	errCh := make(chan error, len(apps))
+	for _, app := range apps {
+		go func(app *App) { errCh <- app.Run() }(app)
+	}
+	for _, app := range apps {
+		waitForPing(c, app)
+	}
+
+	for _, app := range apps {
+		app.Stop()
+	}
+	for range apps {
+		c.Assert(<-errCh, Equals, http.ErrServerClosed)
+	}
+}
+
+func (s *AppSuite) TestShutdown(c *C) {
+	app := newRunnableApp(c)
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+	waitForPing(c, app)
+
+	err := app.Shutdown(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(<-errCh, Equals, http.ErrServerClosed)
+}
+
+func (s *AppSuite) TestShutdownSignal(c *C) {
+	for i, tc := range []struct {
+		signals []os.Signal
+		signal  syscall.Signal
+	}{
+		{nil, syscall.SIGTERM},
+		{[]os.Signal{syscall.SIGUSR2}, syscall.SIGUSR2},
+	} {
+		c.Logf("Test case #%d", i)
+		app := newRunnableApp(c)
+		if tc.signals != nil {
+			app.Config.ShutdownSignals = tc.signals
+		}
+		errCh := make(chan error, 1)
+		go func() { errCh <- app.Run() }()
+		waitForPing(c, app)
+
+		c.Assert(syscall.Kill(os.Getpid(), tc.signal), IsNil)
+
+		select {
+		case err := <-errCh:
+			c.Assert(err, Equals, http.ErrServerClosed)
+		case <-time.After(5 * time.Second):
+			c.Fatal("app did not shut down")
+		}
+	}
+}
+
+func (s *AppSuite) TestShutdownTimeout(c *C) {
+	app := newRunnableApp(c)
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	err := app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/slow"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			close(started)
+			<-release
+			return Response{"message": "OK"}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+	waitForPing(c, app)
+	go http.Get(fmt.Sprintf("http://%s:%d/slow", app.Config.ListenIP, app.Config.ListenPort))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = app.Shutdown(ctx)
+
+	c.Assert(err, Equals, context.DeadlineExceeded)
+	c.Assert(<-errCh, Equals, http.ErrServerClosed)
+}
+
+// writeSelfSignedCert writes a self-signed certificate for 127.0.0.1 and its key to the directory
+// returning the certificate and key file paths, and the certificate itself. The certificate can be
+// used by servers and clients alike.
+func writeSelfSignedCert(c *C, dir string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+	cert, err := x509.ParseCertificate(der)
+	c.Assert(err, IsNil)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	c.Assert(err, IsNil)
+	return certFile, keyFile, cert
+}
+
+func (s *AppSuite) TestHTTPServerTimeouts(c *C) {
+	config := AppConfig{Name: "test"}
+	config.HTTP.ReadTimeout = 5 * time.Second
+	config.HTTP.WriteTimeout = 15 * time.Second
+	app, err := NewAppWithConfig(config)
+	c.Assert(err, IsNil)
+
+	srv := app.newHTTPServer("127.0.0.1:8080")
+
+	c.Assert(srv.Addr, Equals, "127.0.0.1:8080")
+	c.Assert(srv.ReadTimeout, Equals, 5*time.Second)
+	c.Assert(srv.WriteTimeout, Equals, 15*time.Second)
+	c.Assert(srv.IdleTimeout, Equals, defaultHTTPIdleTimeout)
+}
+
+func (s *AppSuite) TestRunTLS(c *C) {
+	app := newRunnableApp(c)
+	certFile, keyFile, cert := writeSelfSignedCert(c, c.MkDir())
+	app.Config.TLS.CertFile = certFile
+	app.Config.TLS.KeyFile = keyFile
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+	defer func() {
+		app.Stop()
+		c.Assert(<-errCh, Equals, http.ErrServerClosed)
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	waitForURL(c, client, fmt.Sprintf("https://%s:%d/_ping", app.Config.ListenIP, app.Config.ListenPort))
+
+	response, err := http.Get(fmt.Sprintf("http://%s:%d/_ping", app.Config.ListenIP, app.Config.ListenPort))
+	c.Assert(err, IsNil)
+	response.Body.Close()
+	c.Assert(response.StatusCode, Equals, http.StatusBadRequest)
+}
+
+func (s *AppSuite) TestRunMutualTLS(c *C) {
+	app := newRunnableApp(c)
+	certFile, keyFile, cert := writeSelfSignedCert(c, c.MkDir())
+	clientCertFile, clientKeyFile, clientCert := writeSelfSignedCert(c, c.MkDir())
+	app.Config.TLS.CertFile = certFile
+	app.Config.TLS.KeyFile = keyFile
+	app.Config.TLS.ClientCAs = x509.NewCertPool()
+	app.Config.TLS.ClientCAs.AddCert(clientCert)
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+	defer func() {
+		app.Stop()
+		c.Assert(<-errCh, Equals, http.ErrServerClosed)
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	keyPair, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+	c.Assert(err, IsNil)
+	url := fmt.Sprintf("https://%s:%d/_ping", app.Config.ListenIP, app.Config.ListenPort)
+
+	// A client with a valid certificate is served.
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{keyPair}}}}
+	waitForURL(c, client, url)
+
+	// A client without a certificate is rejected during the handshake.
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	_, err = client.Get(url)
+	c.Assert(err, ErrorMatches, ".*tls: .*certificate.*")
+}
+
+func (s *AppSuite) TestRunUnixSocket(c *C) {
+	socket := filepath.Join(c.MkDir(), "app.sock")
+	app, err := NewAppWithConfig(AppConfig{Name: "test", ListenNetwork: "unix", ListenPath: socket, DisableRegistration: true})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/hello"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"message": "Hello World"}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	waitForURL(c, client, "http://unix/hello")
+
+	app.Stop()
+	c.Assert(<-errCh, Equals, http.ErrServerClosed)
+	_, err = os.Stat(socket)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *AppSuite) TestRunWithListener(c *C) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	app, err := NewAppWithConfig(AppConfig{Name: "test", DisableRegistration: true})
+	c.Assert(err, IsNil)
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.RunWithListener(listener) }()
+
+	waitForURL(c, http.DefaultClient, fmt.Sprintf("http://%s/_ping", listener.Addr()))
+
+	app.Stop()
+	c.Assert(<-errCh, Equals, http.ErrServerClosed)
+}
+
+func (s *AppSuite) TestRunAddressInUse(c *C) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+	app, err := NewAppWithConfig(AppConfig{Name: "test", ListenIP: "127.0.0.1", ListenPort: port, DisableRegistration: true})
+	c.Assert(err, IsNil)
+
+	err = app.Run()
+
+	c.Assert(err, ErrorMatches, fmt.Sprintf("failed to listen on 127.0.0.1:%d: .*address already in use", port))
+}
+
+func (s *AppSuite) TestValidate(c *C) {
+	for i, tc := range []struct {
+		config AppConfig
+		err    string
+	}{{
+		config: AppConfig{Name: "test", ListenPort: 8080, PublicAPIHost: "api.example.com"},
+	}, {
+		config: AppConfig{ListenPort: 8080, DisableRegistration: true},
+	}, {
+		config: AppConfig{ListenNetwork: "unix", ListenPath: "/tmp/app.sock", DisableRegistration: true},
+	}, {
+		config: AppConfig{ListenPort: 8080, PublicAPIHost: "api.example.com"},
+		err:    "invalid config: a name is required for vulcand registration, .*",
+	}, {
+		config: AppConfig{Name: "test", ListenPort: 8080},
+		err:    "invalid config: a public API host is required for vulcand registration, .*",
+	}, {
+		config: AppConfig{Name: "test", PublicAPIHost: "api.example.com"},
+		err:    "invalid config: a listen port is required for vulcand registration, .*",
+	}, {
+		config: AppConfig{ListenPort: 80800, DisableRegistration: true},
+		err:    "invalid listen port 80800: must be between 0 and 65535",
+	}, {
+		config: AppConfig{ListenNetwork: "unix", DisableRegistration: true},
+		err:    "invalid config: a listen path is required to listen on a unix socket",
+	}, {
+		config: AppConfig{ListenNetwork: "udp", DisableRegistration: true},
+		err:    `invalid listen network "udp": must be either tcp or unix`,
+	}} {
+		c.Logf("Test case #%d", i)
+
+		err := tc.config.Validate()
+
+		if tc.err == "" {
+			c.Assert(err, IsNil)
+		} else {
+			c.Assert(err, ErrorMatches, tc.err)
+		}
+	}
+}
+
+func (s *AppSuite) TestRunInvalidConfig(c *C) {
+	app, err := NewAppWithConfig(AppConfig{ListenIP: "127.0.0.1", ListenPort: 8080, PublicAPIHost: "api.example.com"})
+	c.Assert(err, IsNil)
+
+	err = app.Run()
+
+	c.Assert(err, ErrorMatches, "invalid config: a name is required for vulcand registration, .*")
+}
+
+func (s *AppSuite) TestUse(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	var trace []string
+	tracer := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace = append(trace, name+" before")
+				next.ServeHTTP(w, r)
+				trace = append(trace, name+" after")
+			})
+		}
+	}
+	app.Use(tracer("first"))
+	err = app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/hello"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			trace = append(trace, "handler")
+			return Response{"message": "Hello World"}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+	app.Use(tracer("second"))
+
+	recorder := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/hello", nil))
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(trace, DeepEquals, []string{"first before", "second before", "handler", "second after", "first after"})
+}
+
+func (s *AppSuite) TestRouter(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	app.Router().Handle("/static/file.txt", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("static"))
+	}))
+
+	recorder := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/static/file.txt", nil))
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), Equals, "static")
+}
+
+func (s *AppSuite) TestStrictSlash(c *C) {
+	for i, tc := range []struct {
+		strictSlash bool
+		status      int
+		location    string
+	}{
+		{strictSlash: true, status: http.StatusMovedPermanently, location: "/users"},
+		{strictSlash: false, status: http.StatusNotFound},
+	} {
+		c.Logf("Test case #%d", i)
+		app, err := NewAppWithConfig(AppConfig{Name: "test", StrictSlash: tc.strictSlash})
+		c.Assert(err, IsNil)
+		err = app.AddHandler(Spec{
+			Methods: []string{"GET"},
+			Paths:   []string{"/users"},
+			Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+				return Response{}, nil
+			},
+		})
+		c.Assert(err, IsNil)
+
+		recorder := httptest.NewRecorder()
+		app.GetHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/users/", nil))
+
+		c.Assert(recorder.Code, Equals, tc.status)
+		c.Assert(recorder.Header().Get("Location"), Equals, tc.location)
+	}
+}
+
+func (s *AppSuite) TestQueries(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	for _, searchType := range []string{"fuzzy", "exact"} {
+		searchType := searchType
+		err = app.AddHandler(Spec{
+			Methods: []string{"GET"},
+			Paths:   []string{"/search"},
+			Queries: []string{"type", searchType},
+			Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+				return Response{"type": searchType}, nil
+			},
+		})
+		c.Assert(err, IsNil)
+	}
+
+	for _, searchType := range []string{"fuzzy", "exact"} {
+		recorder := httptest.NewRecorder()
+		app.GetHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/search?q=foo&type="+searchType, nil))
+
+		c.Assert(recorder.Code, Equals, http.StatusOK)
+		c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"type": searchType})
+	}
+
+	err = app.AddHandler(Spec{Methods: []string{"GET"}, Paths: []string{"/odd"}, Queries: []string{"type"}, RawHandler: handlePing})
+	c.Assert(err, ErrorMatches, "the spec queries must be key/value pairs: .*")
+}
+
+func (s *AppSuite) TestPathPattern(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/messages/{id:[0-9]{3}}"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"id": params["id"]}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	recorder := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/messages/123", nil))
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"id": "123"})
+
+	for _, path := range []string{"/messages/abc", "/messages/1234"} {
+		recorder = httptest.NewRecorder()
+		app.GetHandler().ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		c.Assert(recorder.Code, Equals, http.StatusNotFound)
+	}
+}
+
+func (s *AppSuite) TestAnyMethod(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Paths: []string{"/webhooks"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"method": r.Method}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	for _, method := range []string{"GET", "POST", "DELETE"} {
+		recorder := httptest.NewRecorder()
+		app.GetHandler().ServeHTTP(recorder, httptest.NewRequest(method, "/webhooks", nil))
+
+		c.Assert(recorder.Code, Equals, http.StatusOK)
+		c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"method": method})
+	}
+
+	err = app.AddHandler(Spec{Paths: []string{"/webhooks"}, RawHandler: handlePing})
+	c.Assert(err, ErrorMatches, `the route is already registered: \* /webhooks`)
+}
+
+func (s *AppSuite) TestAnyMethodConflicts(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{Paths: []string{"/webhooks"}, RawHandler: handlePing})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{Methods: []string{"POST"}, Paths: []string{"/events"}, RawHandler: handlePing})
+	c.Assert(err, IsNil)
+
+	// A route for any method conflicts with every method on the same path, and the other way round.
+	err = app.AddHandler(Spec{Methods: []string{"POST"}, Paths: []string{"/webhooks"}, RawHandler: handlePing})
+	c.Assert(err, ErrorMatches, `the route is already registered: \* /webhooks`)
+	err = app.AddHandler(Spec{Paths: []string{"/events"}, RawHandler: handlePing})
+	c.Assert(err, ErrorMatches, `the route is already registered: POST /events`)
+
+	// Other paths are not affected.
+	err = app.AddHandler(Spec{Methods: []string{"POST"}, Paths: []string{"/webhooks/{id}"}, RawHandler: handlePing})
+	c.Assert(err, IsNil)
+}
+
+func (s *AppSuite) TestInvalidPath(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+
+	for i, path := range []string{"", "messages"} {
+		c.Logf("Test case #%d", i)
+		err = app.AddHandler(Spec{Methods: []string{"GET"}, Paths: []string{"/ok", path}, RawHandler: handlePing})
+		c.Assert(err, ErrorMatches, "the spec paths must start with a slash: .*")
+	}
+	c.Assert(app.Specs(), HasLen, 0)
+}
+
+func (s *AppSuite) TestOddHeaders(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+
+	err = app.AddHandler(Spec{
+		Methods:    []string{"GET"},
+		Paths:      []string{"/odd"},
+		Headers:    []string{"Content-Type", "application/json", "Accept"},
+		RawHandler: handlePing,
+	})
+
+	c.Assert(err, ErrorMatches, `the spec headers must be key/value pairs: \[Content-Type application/json Accept\]`)
+	recorder := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/odd", nil))
+	c.Assert(recorder.Code, Equals, http.StatusNotFound)
+}
+
+func (s *AppSuite) TestSpecs(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{Methods: []string{"GET"}, Paths: []string{"/ping", "/health"}, MetricName: "ping", RawHandler: handlePing})
+	c.Assert(err, IsNil)
+	err = app.Group("/v2").AddHandler(Spec{Methods: []string{"POST", "PUT"}, Paths: []string{"/messages"}, MetricName: "messages", RawHandler: handlePing})
+	c.Assert(err, IsNil)
+
+	specs := app.Specs()
+
+	c.Assert(specs, HasLen, 2)
+	c.Assert(specs[0].Methods, DeepEquals, []string{"GET"})
+	c.Assert(specs[0].Paths, DeepEquals, []string{"/ping", "/health"})
+	c.Assert(specs[0].MetricName, Equals, "ping")
+	c.Assert(specs[1].Methods, DeepEquals, []string{"POST", "PUT"})
+	c.Assert(specs[1].Paths, DeepEquals, []string{"/v2/messages"})
+	c.Assert(specs[1].MetricName, Equals, "messages")
+}
+
+func (s *AppSuite) TestDuplicateRoute(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{Methods: []string{"GET", "PUT"}, Paths: []string{"/resources/{id}"}, RawHandler: handlePing})
+	c.Assert(err, IsNil)
+
+	err = app.AddHandler(Spec{Methods: []string{"DELETE", "PUT"}, Paths: []string{"/resources/{id}"}, RawHandler: handlePing})
+	c.Assert(err, ErrorMatches, `the route is already registered: PUT /resources/\{id\}`)
+	err = app.AddHandler(Spec{Methods: []string{"get"}, Paths: []string{"/resources/{id}"}, RawHandler: handlePing})
+	c.Assert(err, ErrorMatches, `the route is already registered: GET /resources/\{id\}`)
+	c.Assert(app.Specs(), HasLen, 1)
+
+	// The same path is allowed for other methods, or when distinguished by query parameters.
+	err = app.AddHandler(Spec{Methods: []string{"DELETE"}, Paths: []string{"/resources/{id}"}, RawHandler: handlePing})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{Methods: []string{"GET"}, Paths: []string{"/resources/{id}"}, Queries: []string{"v", "2"}, RawHandler: handlePing})
+	c.Assert(err, IsNil)
+}
+
+func (s *AppSuite) TestAddRoute(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+
+	route, err := app.AddRoute(Spec{
+		Methods:    []string{"GET"},
+		Paths:      []string{"/domains/{domain}/messages/{id}", "/messages/{id}"},
+		RawHandler: handlePing,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(route.Routes(), HasLen, 2)
+
+	u, err := route.URL("domain", "example.com", "id", "42")
+	c.Assert(err, IsNil)
+	c.Assert(u.String(), Equals, "/domains/example.com/messages/42")
+
+	u, err = route.Routes()[1].URL("id", "42")
+	c.Assert(err, IsNil)
+	c.Assert(u.String(), Equals, "/messages/42")
+
+	route.Route().Name("message")
+	u, err = app.URL("message", "domain", "example.com", "id", "43")
+	c.Assert(err, IsNil)
+	c.Assert(u.String(), Equals, "/domains/example.com/messages/43")
+
+	// The spec's paths include the group prefix.
+	route, err = app.Group("/v2").AddRoute(Spec{Methods: []string{"GET"}, Paths: []string{"/messages/{id}"}, RawHandler: handlePing})
+	c.Assert(err, IsNil)
+	c.Assert(route.Spec.Paths, DeepEquals, []string{"/v2/messages/{id}"})
+	u, err = route.URL("id", "42")
+	c.Assert(err, IsNil)
+	c.Assert(u.String(), Equals, "/v2/messages/42")
+
+	route, err = app.AddRoute(Spec{Methods: []string{"GET"}, Paths: []string{"/messages/{id}"}, RawHandler: handlePing})
+	c.Assert(err, NotNil)
+	c.Assert(route, IsNil)
+}
+
+func (s *AppSuite) TestScope(c *C) {
+	config := AppConfig{Name: "test", ListenIP: "127.0.0.1", ListenPort: 8080, Vulcand: &vulcand.Config{},
+		PublicAPIHost: "api.example.com", ProtectedAPIHost: "api.internal"}
+	app, err := NewAppWithConfig(config)
+	c.Assert(err, IsNil)
+
+	// Public and protected handlers are routed via different hosts.
+	host, err := app.apiHostForScope(ScopePublic)
+	c.Assert(err, IsNil)
+	c.Assert(host, Equals, "api.example.com")
+	host, err = app.apiHostForScope(ScopeProtected)
+	c.Assert(err, IsNil)
+	c.Assert(host, Equals, "api.internal")
+
+	err = app.AddHandler(Spec{Methods: []string{"GET"}, Paths: []string{"/public"}, RawHandler: handlePing})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{Methods: []string{"GET"}, Paths: []string{"/protected"}, Scope: ScopeProtected, RawHandler: handlePing})
+	c.Assert(err, IsNil)
+}
+
+func (s *AppSuite) TestScopeProtectedWithoutHost(c *C) {
+	config := AppConfig{Name: "test", ListenIP: "127.0.0.1", ListenPort: 8080, Vulcand: &vulcand.Config{},
+		PublicAPIHost: "api.example.com"}
+	app, err := NewAppWithConfig(config)
+	c.Assert(err, IsNil)
+
+	err = app.AddHandler(Spec{Methods: []string{"GET"}, Paths: []string{"/protected"}, Scope: ScopeProtected, RawHandler: handlePing})
+
+	c.Assert(err, ErrorMatches, "protected handlers require a protected API host to be configured")
+	c.Assert(app.Specs(), HasLen, 0)
+}
+
+func (s *AppSuite) TestURL(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/domains/{domain}/messages/{id}"},
+		Name:    "message",
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	u, err := app.URL("message", "domain", "example.com", "id", "42")
+	c.Assert(err, IsNil)
+	c.Assert(u.String(), Equals, "/domains/example.com/messages/42")
+
+	_, err = app.URL("message", "domain", "example.com")
+	c.Assert(err, NotNil)
+
+	_, err = app.URL("unknown")
+	c.Assert(err, ErrorMatches, `no route named "unknown"`)
+}
+
+func (s *AppSuite) TestDisableRegistration(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	c.Assert(app.vulcandReg, NotNil)
+
+	app, err = NewAppWithConfig(AppConfig{Name: "test", DisableRegistration: true})
+	c.Assert(err, IsNil)
+	c.Assert(app.vulcandReg, IsNil)
+}
+
+// Stopping the app exits the registration loop and deregisters its server from vulcand.
+func (s *AppSuite) TestStopDeregisters(c *C) {
+	defer setEnv(map[string]string{"MG_ENV": ""})()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	port := l.Addr().(*net.TCPAddr).Port
+	c.Assert(l.Close(), IsNil)
+
+	// This assumes we used to `docker-compose up` to create the etcd node
+	etcdCfg := &etcd.Config{
+		Endpoints: []string{"https://localhost:2379"},
+		Username:  "root",
+		Password:  "rootpw",
+		TLS: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	}
+	app, err := NewAppWithConfig(AppConfig{
+		Name:          "test-registration",
+		ListenIP:      "127.0.0.1",
+		ListenPort:    port,
+		PublicAPIHost: "api.example.com",
+		Vulcand:       &vulcand.Config{Namespace: testNamespace, Etcd: etcdCfg, TTL: time.Second},
+	})
+	c.Assert(err, IsNil)
+	client, err := etcd.New(*etcdCfg)
+	c.Assert(err, IsNil)
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	serversKey := testNamespace + "/backends/test-registration/servers"
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+	waitForPing(c, app)
+
+	res, err := client.Get(ctx, serversKey, etcd.WithPrefix())
+	c.Assert(err, IsNil)
+	c.Assert(res.Kvs, HasLen, 1)
+
+	// When
+	stopped := make(chan struct{})
+	go func() {
+		app.Stop()
+		close(stopped)
+	}()
+
+	// Then
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		c.Fatal("registration loop did not exit")
+	}
+	c.Assert(<-errCh, Equals, http.ErrServerClosed)
+
+	res, err = client.Get(ctx, serversKey, etcd.WithPrefix())
+	c.Assert(err, IsNil)
+	c.Assert(res.Kvs, HasLen, 0)
+}
+
+func (s *AppSuite) TestMultiPathMetricName(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	app.stats.prom = newPromCollector()
+	err = app.AddHandler(Spec{
+		Methods:    []string{"GET"},
+		Paths:      []string{"/ping", "/health", "/v2/ping/{id}"},
+		MetricName: "ping",
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"message": "pong"}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	for _, path := range []string{"/ping", "/health", "/v2/ping/1"} {
+		app.GetHandler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// All the paths are tracked under the metric name rather than under their route templates.
+	c.Assert(app.stats.prom.handlers, HasLen, 1)
+	c.Assert(app.stats.prom.handlers["ping"].counts[http.StatusOK], Equals, uint64(3))
+}
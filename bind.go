@@ -0,0 +1,89 @@
+package scroll
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Validator is implemented by request bodies that can validate themselves after being bound.
+type Validator interface {
+	Validate() error
+}
+
+// BindJSON unmarshals the JSON request body into dest.
+//
+// Returns `InvalidJSONError` if the body is not valid JSON or does not fit dest. If dest implements
+// `Validator` it is validated after unmarshalling and a validation failure is returned as
+// `ValidationError`, unless it is already `ValidationErrors`.
+func BindJSON(body []byte, dest interface{}) error {
+	return BindJSONWithOptions(body, dest, BindOptions{})
+}
+
+// BindOptions override the default behavior of BindJSON.
+type BindOptions struct {
+	// If true, numbers bound into interface{} values are decoded as json.Number instead of float64,
+	// so that large integers such as int64 IDs do not lose precision.
+	UseNumber bool
+}
+
+// BindJSONWithOptions binds the JSON request body into dest just like BindJSON, in addition overriding
+// the default decoding with the provided options.
+func BindJSONWithOptions(body []byte, dest interface{}, opts BindOptions) error {
+	if err := unmarshalJSON(body, dest, opts); err != nil {
+		return InvalidJSONError{err.Error()}
+	}
+	if validator, ok := dest.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			if errs, ok := err.(ValidationErrors); ok {
+				return errs
+			}
+			return ValidationError{err.Error()}
+		}
+	}
+	return nil
+}
+
+func unmarshalJSON(body []byte, dest interface{}, opts BindOptions) error {
+	if !opts.UseNumber {
+		return json.Unmarshal(body, dest)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(dest); err != nil {
+		return err
+	}
+	// Reject trailing data just like json.Unmarshal does.
+	if err := decoder.Decode(&json.RawMessage{}); err != io.EOF {
+		if err == nil {
+			return errors.New("invalid character after top-level value")
+		}
+		return err
+	}
+	return nil
+}
+
+// CheckBatchSize returns `BatchTooLargeError` if a batch of n entries exceeds MaxBatchSize.
+func CheckBatchSize(n int) error {
+	if n > MaxBatchSize {
+		return BatchTooLargeError{n, MaxBatchSize}
+	}
+	return nil
+}
+
+// DecodeJSONBatch unmarshals a JSON array request body into dest, which should be a pointer to a slice.
+//
+// Returns `BatchTooLargeError` if the array has more than MaxBatchSize entries, otherwise behaves
+// just like BindJSON.
+func DecodeJSONBatch(body []byte, dest interface{}) error {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return InvalidJSONError{err.Error()}
+	}
+	if err := CheckBatchSize(len(entries)); err != nil {
+		return err
+	}
+	return BindJSON(body, dest)
+}
@@ -0,0 +1,139 @@
+package scroll
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type BindSuite struct{}
+
+var _ = Suite(&BindSuite{})
+
+type testMessage struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+}
+
+func (m *testMessage) Validate() error {
+	if m.To == "" {
+		return errors.New("recipient is required")
+	}
+	return nil
+}
+
+func (s *BindSuite) TestBindJSON(c *C) {
+	var message testMessage
+
+	err := BindJSON([]byte(`{"to": "bob@example.com", "subject": "hi"}`), &message)
+
+	c.Assert(err, IsNil)
+	c.Assert(message, Equals, testMessage{To: "bob@example.com", Subject: "hi"})
+}
+
+func (s *BindSuite) TestBindJSONMalformed(c *C) {
+	var message testMessage
+
+	err := BindJSON([]byte(`{"to": `), &message)
+
+	c.Assert(err, FitsTypeOf, InvalidJSONError{})
+	_, status := responseAndStatusFor(err)
+	c.Assert(status, Equals, http.StatusBadRequest)
+}
+
+func (s *BindSuite) TestBindJSONValidationFailed(c *C) {
+	var message testMessage
+
+	err := BindJSON([]byte(`{"subject": "hi"}`), &message)
+
+	c.Assert(err, Equals, ValidationError{"recipient is required"})
+	response, status := responseAndStatusFor(err)
+	c.Assert(status, Equals, http.StatusUnprocessableEntity)
+	c.Assert(response, DeepEquals, Response{"message": "Validation failed: recipient is required", "code": "VALIDATION_FAILED"})
+}
+
+func (s *BindSuite) TestBindJSONWithOptionsUseNumber(c *C) {
+	body := []byte(`{"id": 12345678901234567, "ratio": 0.5}`)
+
+	var precise map[string]interface{}
+	err := BindJSONWithOptions(body, &precise, BindOptions{UseNumber: true})
+	c.Assert(err, IsNil)
+	c.Assert(precise["id"], Equals, json.Number("12345678901234567"))
+	id, err := precise["id"].(json.Number).Int64()
+	c.Assert(err, IsNil)
+	c.Assert(id, Equals, int64(12345678901234567))
+	c.Assert(precise["ratio"], Equals, json.Number("0.5"))
+
+	// By default numbers are decoded as float64 and lose precision.
+	var lossy map[string]interface{}
+	err = BindJSON(body, &lossy)
+	c.Assert(err, IsNil)
+	c.Assert(int64(lossy["id"].(float64)), Not(Equals), int64(12345678901234567))
+}
+
+func (s *BindSuite) TestBindJSONWithOptionsUseNumberMalformed(c *C) {
+	for i, body := range []string{`{"id": `, `{"id": 1} {}`, `{"id": 1}}`} {
+		c.Logf("Test case #%d", i)
+		var dest map[string]interface{}
+
+		err := BindJSONWithOptions([]byte(body), &dest, BindOptions{UseNumber: true})
+
+		c.Assert(err, FitsTypeOf, InvalidJSONError{})
+	}
+}
+
+func (s *BindSuite) TestCheckBatchSize(c *C) {
+	c.Assert(CheckBatchSize(MaxBatchSize), IsNil)
+	c.Assert(CheckBatchSize(MaxBatchSize+1), Equals, BatchTooLargeError{MaxBatchSize + 1, MaxBatchSize})
+
+	_, status := responseAndStatusFor(CheckBatchSize(MaxBatchSize + 1))
+	c.Assert(status, Equals, http.StatusRequestEntityTooLarge)
+}
+
+func (s *BindSuite) TestDecodeJSONBatch(c *C) {
+	batch := func(n int) []byte {
+		return []byte("[" + strings.TrimSuffix(strings.Repeat(`{"to": "bob@example.com"},`, n), ",") + "]")
+	}
+	var messages []testMessage
+
+	err := DecodeJSONBatch(batch(MaxBatchSize), &messages)
+	c.Assert(err, IsNil)
+	c.Assert(messages, HasLen, MaxBatchSize)
+	c.Assert(messages[0].To, Equals, "bob@example.com")
+
+	err = DecodeJSONBatch(batch(MaxBatchSize+1), &messages)
+	c.Assert(err, Equals, BatchTooLargeError{MaxBatchSize + 1, MaxBatchSize})
+
+	err = DecodeJSONBatch([]byte(`{"to": "bob@example.com"}`), &messages)
+	c.Assert(err, FitsTypeOf, InvalidJSONError{})
+}
+
+type testRecipient struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+func (r *testRecipient) Validate() error {
+	errs := ValidationErrors{}
+	if r.Email == "" {
+		errs["email"] = "is required"
+	}
+	if r.Name == "" {
+		errs["name"] = "is required"
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+func (s *BindSuite) TestBindJSONValidationErrors(c *C) {
+	var recipient testRecipient
+
+	err := BindJSON([]byte(`{}`), &recipient)
+
+	c.Assert(err, DeepEquals, ValidationErrors{"email": "is required", "name": "is required"})
+}
@@ -0,0 +1,99 @@
+package scroll
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// TrustedProxies lists the networks, in CIDR notation, of the proxies (e.g. vulcand) whose
+// `X-Forwarded-For` and `X-Real-IP` headers ClientIP honors. Defaults to loopback and private networks.
+var TrustedProxies = []string{"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "::1/128", "fc00::/7"}
+
+// ClientIP returns the IP address of the client that made the request.
+//
+// If the request came from a trusted proxy, the `X-Forwarded-For` hops are inspected from the right,
+// i.e. starting with the hop closest to the app, and the first hop that is not a trusted proxy is
+// returned. Hops to the left of it are not trusted since the client can forge them. If there is no
+// `X-Forwarded-For` header `X-Real-IP` is used. Otherwise, or if the forwarded address is not a valid
+// IP address, the request's remote address is returned.
+func ClientIP(r *http.Request) string {
+	remoteIP := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remoteIP = host
+	}
+	trusted := trustedProxies()
+	if !isTrustedProxy(remoteIP, trusted) {
+		return remoteIP
+	}
+
+	if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+		hops := strings.Split(forwardedFor, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if !isTrustedProxy(hop, trusted) {
+				if net.ParseIP(hop) == nil {
+					return remoteIP
+				}
+				return hop
+			}
+		}
+		// The whole chain consists of trusted proxies, the left-most one is the closest to the client.
+		return strings.TrimSpace(hops[0])
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return remoteIP
+}
+
+// The networks parsed from TrustedProxies, they are parsed again only if TrustedProxies changes.
+var parsedProxies struct {
+	sync.Mutex
+	cidrs    []string
+	networks []*net.IPNet
+}
+
+// trustedProxies returns the networks of TrustedProxies, invalid networks are skipped.
+func trustedProxies() []*net.IPNet {
+	parsedProxies.Lock()
+	defer parsedProxies.Unlock()
+	if equalStrings(parsedProxies.cidrs, TrustedProxies) && parsedProxies.networks != nil {
+		return parsedProxies.networks
+	}
+	networks := make([]*net.IPNet, 0, len(TrustedProxies))
+	for _, cidr := range TrustedProxies {
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	parsedProxies.cidrs = append([]string(nil), TrustedProxies...)
+	parsedProxies.networks = networks
+	return networks
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isTrustedProxy(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
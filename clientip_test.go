@@ -0,0 +1,63 @@
+package scroll
+
+import (
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+)
+
+type ClientIPSuite struct{}
+
+var _ = Suite(&ClientIPSuite{})
+
+func (s *ClientIPSuite) TestClientIP(c *C) {
+	for i, tc := range []struct {
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		expectedIP   string
+	}{
+		// Direct connections.
+		{remoteAddr: "203.0.113.7:5000", expectedIP: "203.0.113.7"},
+		{remoteAddr: "[2001:db8::1]:5000", expectedIP: "2001:db8::1"},
+		// Forwarding headers sent by untrusted clients are ignored.
+		{remoteAddr: "203.0.113.7:5000", forwardedFor: "198.51.100.1", expectedIP: "203.0.113.7"},
+		{remoteAddr: "203.0.113.7:5000", realIP: "198.51.100.1", expectedIP: "203.0.113.7"},
+		// Forwarded by a trusted proxy.
+		{remoteAddr: "10.0.0.2:5000", forwardedFor: "198.51.100.1", expectedIP: "198.51.100.1"},
+		{remoteAddr: "10.0.0.2:5000", realIP: "198.51.100.1", expectedIP: "198.51.100.1"},
+		{remoteAddr: "10.0.0.2:5000", forwardedFor: "198.51.100.1", realIP: "198.51.100.2", expectedIP: "198.51.100.1"},
+		// Forwarded chains: the hop closest to the trusted proxies wins over forged hops.
+		{remoteAddr: "10.0.0.2:5000", forwardedFor: "192.0.2.9, 198.51.100.1, 10.0.0.3", expectedIP: "198.51.100.1"},
+		{remoteAddr: "10.0.0.2:5000", forwardedFor: "garbage, 198.51.100.1", expectedIP: "198.51.100.1"},
+		{remoteAddr: "10.0.0.2:5000", forwardedFor: "10.0.0.4, 10.0.0.3", expectedIP: "10.0.0.4"},
+		// Forwarded addresses that are not valid IP addresses are ignored.
+		{remoteAddr: "10.0.0.2:5000", forwardedFor: "198.51.100.1, garbage", expectedIP: "10.0.0.2"},
+		{remoteAddr: "10.0.0.2:5000", realIP: "garbage", expectedIP: "10.0.0.2"},
+		// No forwarding headers.
+		{remoteAddr: "127.0.0.1:5000", expectedIP: "127.0.0.1"},
+	} {
+		c.Logf("Test case #%d", i)
+		request := httptest.NewRequest("GET", "/", nil)
+		request.RemoteAddr = tc.remoteAddr
+		if tc.forwardedFor != "" {
+			request.Header.Set("X-Forwarded-For", tc.forwardedFor)
+		}
+		if tc.realIP != "" {
+			request.Header.Set("X-Real-IP", tc.realIP)
+		}
+
+		c.Assert(ClientIP(request), Equals, tc.expectedIP)
+	}
+}
+
+func (s *ClientIPSuite) TestTrustedProxies(c *C) {
+	defer func(trusted []string) { TrustedProxies = trusted }(TrustedProxies)
+	TrustedProxies = []string{"203.0.113.0/24", "invalid"}
+
+	request := httptest.NewRequest("GET", "/", nil)
+	request.RemoteAddr = "203.0.113.7:5000"
+	request.Header.Set("X-Forwarded-For", "198.51.100.1, 10.0.0.3")
+
+	c.Assert(ClientIP(request), Equals, "10.0.0.3")
+}
@@ -0,0 +1,131 @@
+package scroll
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// Responses smaller than this number of bytes are not compressed unless Spec.CompressMinSize says otherwise.
+const DefaultCompressMinSize = 1024
+
+// compressHandler wraps the provided handler compressing its responses with gzip if the client accepts it.
+//
+// Responses that are smaller than minSize or that already have a Content-Encoding set by the handler are
+// written as is.
+func compressHandler(handler http.HandlerFunc, minSize int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			handler(w, r)
+			return
+		}
+
+		gzw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize, status: http.StatusOK}
+		handler(gzw, r)
+		gzw.close()
+	}
+}
+
+// gzipResponseWriter buffers the beginning of the response body to decide whether it is worth compressing.
+// Once minSize bytes are written, or the response is flushed, the headers are sent and the body is streamed
+// through a gzip writer.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+	status  int
+	buf     bytes.Buffer
+
+	// Set once the headers are sent, gz is nil if the response is written as is.
+	started bool
+	gz      *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if !w.started {
+		w.status = status
+	}
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.started {
+		w.buf.Write(b)
+		if w.buf.Len() < w.minSize {
+			return len(b), nil
+		}
+		return len(b), w.start(true)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends the body written so far, compressed unless the handler set a Content-Encoding: a flushed
+// response is streamed, hence it is compressed even though its beginning is smaller than minSize.
+func (w *gzipResponseWriter) Flush() {
+	if !w.started {
+		if err := w.start(true); err != nil {
+			return
+		}
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// start sends the headers and the buffered body, compressed if compress is set and the handler did not
+// set a Content-Encoding.
+func (w *gzipResponseWriter) start(compress bool) error {
+	w.started = true
+	header := w.ResponseWriter.Header()
+	if compress && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return err
+}
+
+func (w *gzipResponseWriter) close() error {
+	if !w.started {
+		if err := w.start(w.buf.Len() >= w.minSize); err != nil {
+			return err
+		}
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// acceptsGzip determines whether the client accepts gzip encoded responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(encoding, ";")
+		if strings.TrimSpace(parts[0]) != "gzip" {
+			continue
+		}
+		if len(parts) > 1 && strings.Replace(strings.TrimSpace(parts[1]), " ", "", -1) == "q=0" {
+			return false
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package scroll
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type CompressSuite struct{}
+
+var _ = Suite(&CompressSuite{})
+
+func (s *CompressSuite) newApp(c *C, minSize int) *App {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Methods:         []string{"GET"},
+		Paths:           []string{"/messages"},
+		Compress:        true,
+		CompressMinSize: minSize,
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"message": strings.Repeat("a", 2048)}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+	return app
+}
+
+func (s *CompressSuite) serve(app *App, acceptEncoding string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("GET", "/messages", nil)
+	if acceptEncoding != "" {
+		request.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	recorder := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (s *CompressSuite) TestGzip(c *C) {
+	recorder := s.serve(s.newApp(c, 0), "deflate, gzip")
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Encoding"), Equals, "gzip")
+	c.Assert(recorder.Header().Get("Content-Type"), Equals, "application/json; charset=utf-8")
+	gz, err := gzip.NewReader(recorder.Body)
+	c.Assert(err, IsNil)
+	body, err := ioutil.ReadAll(gz)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Equals, `{"message":"`+strings.Repeat("a", 2048)+`"}`)
+}
+
+func (s *CompressSuite) TestNoAcceptEncoding(c *C) {
+	for _, acceptEncoding := range []string{"", "deflate", "gzip;q=0"} {
+		recorder := s.serve(s.newApp(c, 0), acceptEncoding)
+
+		c.Assert(recorder.Code, Equals, http.StatusOK)
+		c.Assert(recorder.Header().Get("Content-Encoding"), Equals, "")
+		c.Assert(recorder.Body.String(), Equals, `{"message":"`+strings.Repeat("a", 2048)+`"}`)
+	}
+}
+
+func (s *CompressSuite) TestBelowMinSize(c *C) {
+	recorder := s.serve(s.newApp(c, 4096), "gzip")
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Encoding"), Equals, "")
+	c.Assert(recorder.Body.String(), Equals, `{"message":"`+strings.Repeat("a", 2048)+`"}`)
+}
+
+func (s *CompressSuite) TestAlreadyEncoded(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Methods:  []string{"GET"},
+		Paths:    []string{"/messages"},
+		Compress: true,
+		RawHandler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "br")
+			w.Write([]byte(strings.Repeat("b", 2048)))
+		},
+	})
+	c.Assert(err, IsNil)
+
+	recorder := s.serve(app, "gzip")
+
+	c.Assert(recorder.Header().Get("Content-Encoding"), Equals, "br")
+	c.Assert(recorder.Body.String(), Equals, strings.Repeat("b", 2048))
+}
+
+func (s *CompressSuite) TestResponseSizeUncompressed(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	var size int64
+	err = app.AddHandler(Spec{
+		Methods:  []string{"GET"},
+		Paths:    []string{"/messages"},
+		Compress: true,
+		LogRequest: func(r *http.Request, status int, elapsedTime time.Duration, err error) {
+			size = ResponseSizeFromContext(r.Context())
+		},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"message": strings.Repeat("a", 2048)}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	recorder := s.serve(app, "gzip")
+
+	c.Assert(recorder.Header().Get("Content-Encoding"), Equals, "gzip")
+	c.Assert(size, Equals, int64(len(`{"message":"`+strings.Repeat("a", 2048)+`"}`)))
+}
+
+func (s *CompressSuite) TestFlush(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	recorder := httptest.NewRecorder()
+	var flushed string
+	err = app.AddHandler(Spec{
+		Methods:  []string{"GET"},
+		Paths:    []string{"/events"},
+		Compress: true,
+		RawHandler: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("event: 1\n"))
+			w.(http.Flusher).Flush()
+			flushed = decompress(c, recorder.Body.Bytes())
+			w.Write([]byte("event: 2\n"))
+		},
+	})
+	c.Assert(err, IsNil)
+	request := httptest.NewRequest("GET", "/events", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+
+	app.GetHandler().ServeHTTP(recorder, request)
+
+	// The events written before the flush reached the client while the handler was running, even
+	// though they are smaller than the minimum size.
+	c.Assert(flushed, Equals, "event: 1\n")
+	c.Assert(recorder.Flushed, Equals, true)
+	c.Assert(recorder.Header().Get("Content-Encoding"), Equals, "gzip")
+	c.Assert(decompress(c, recorder.Body.Bytes()), Equals, "event: 1\nevent: 2\n")
+}
+
+// decompress returns the data decompressed from the gzip stream, which may not be complete yet.
+func decompress(c *C, data []byte) string {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	body, err := ioutil.ReadAll(gz)
+	if err != io.ErrUnexpectedEOF {
+		c.Assert(err, IsNil)
+	}
+	return string(body)
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	etcd "github.com/coreos/etcd/clientv3"
@@ -28,10 +31,15 @@ const (
 	defaultHTTPReadTimeout  = 10 * time.Second
 	defaultHTTPWriteTimeout = 60 * time.Second
 	defaultHTTPIdleTimeout  = 60 * time.Second
+	defaultShutdownTimeout  = 60 * time.Second
 	defaultRegistrationTTL  = 30 * time.Second
 	defaultNamespace        = "/vulcand"
+	defaultListenNetwork    = "tcp"
 )
 
+// Signals that trigger a graceful shutdown unless AppConfig.ShutdownSignals is specified.
+var defaultShutdownSignals = []os.Signal{syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM}
+
 func applyDefaults(cfg *AppConfig) error {
 	var err error
 
@@ -41,9 +49,18 @@ func applyDefaults(cfg *AppConfig) error {
 		return errors.Wrap(err, "while creating new etcd config")
 	}
 
+	holster.SetDefault(&cfg.ListenNetwork, defaultListenNetwork)
+
 	holster.SetDefault(&cfg.HTTP.ReadTimeout, defaultHTTPReadTimeout)
 	holster.SetDefault(&cfg.HTTP.WriteTimeout, defaultHTTPWriteTimeout)
 	holster.SetDefault(&cfg.HTTP.IdleTimeout, defaultHTTPIdleTimeout)
+	holster.SetDefault(&cfg.ShutdownTimeout, defaultShutdownTimeout)
+	if len(cfg.ShutdownSignals) == 0 {
+		cfg.ShutdownSignals = defaultShutdownSignals
+	}
+	if cfg.IdempotencyStore == nil {
+		cfg.IdempotencyStore = NewMemoryIdempotencyStore()
+	}
 
 	holster.SetDefault(&cfg.Vulcand.TTL, defaultRegistrationTTL)
 	holster.SetDefault(&cfg.Vulcand.Etcd, &etcd.Config{})
@@ -53,6 +70,77 @@ func applyDefaults(cfg *AppConfig) error {
 	return nil
 }
 
+// Environment variables AppConfigFromEnv reads the app config from.
+const (
+	envName             = "SCROLL_NAME"
+	envVersion          = "SCROLL_VERSION"
+	envListenIP         = "SCROLL_LISTEN_IP"
+	envListenPort       = "SCROLL_LISTEN_PORT"
+	envPublicAPIHost    = "SCROLL_PUBLIC_API_HOST"
+	envProtectedAPIHost = "SCROLL_PROTECTED_API_HOST"
+	envRegister         = "SCROLL_REGISTER"
+	envVulcandNamespace = "SCROLL_VULCAND_NAMESPACE"
+	envShutdownTimeout  = "SCROLL_SHUTDOWN_TIMEOUT"
+)
+
+// AppConfigFromEnv returns an app config populated from the following environment variables, so that
+// apps can be deployed in a uniform way:
+//
+//  SCROLL_NAME                 Name
+//  SCROLL_VERSION              Version
+//  SCROLL_LISTEN_IP            ListenIP, must be an IP address
+//  SCROLL_LISTEN_PORT          ListenPort, must be a port number
+//  SCROLL_PUBLIC_API_HOST      PublicAPIHost
+//  SCROLL_PROTECTED_API_HOST   ProtectedAPIHost
+//  SCROLL_REGISTER             disables vulcand registration if false, i.e. sets DisableRegistration
+//  SCROLL_VULCAND_NAMESPACE    Vulcand.Namespace, not allowed if SCROLL_REGISTER is false
+//  SCROLL_SHUTDOWN_TIMEOUT     ShutdownTimeout, e.g. "30s"
+//
+// Variables that are not set are left empty, so that NewAppWithConfig applies the defaults. The etcd
+// client used for vulcand registration is configured from the ETCD3_* environment variables anyway.
+// The returned config can be adjusted before the app is created, e.g. to set the metrics client.
+func AppConfigFromEnv() (AppConfig, error) {
+	cfg := AppConfig{
+		Name:             os.Getenv(envName),
+		Version:          os.Getenv(envVersion),
+		ListenIP:         os.Getenv(envListenIP),
+		PublicAPIHost:    os.Getenv(envPublicAPIHost),
+		ProtectedAPIHost: os.Getenv(envProtectedAPIHost),
+	}
+	if cfg.ListenIP != "" && net.ParseIP(cfg.ListenIP) == nil {
+		return AppConfig{}, errors.Errorf("invalid %s %q: must be an IP address", envListenIP, cfg.ListenIP)
+	}
+	if value := os.Getenv(envListenPort); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return AppConfig{}, errors.Errorf("invalid %s %q: must be a port number", envListenPort, value)
+		}
+		cfg.ListenPort = port
+	}
+	if value := os.Getenv(envRegister); value != "" {
+		register, err := strconv.ParseBool(value)
+		if err != nil {
+			return AppConfig{}, errors.Errorf("invalid %s %q: must be a boolean", envRegister, value)
+		}
+		cfg.DisableRegistration = !register
+	}
+	if namespace := os.Getenv(envVulcandNamespace); namespace != "" {
+		if cfg.DisableRegistration {
+			return AppConfig{}, errors.Errorf("%s is set but vulcand registration is disabled with %s",
+				envVulcandNamespace, envRegister)
+		}
+		cfg.Vulcand = &vulcand.Config{Namespace: namespace}
+	}
+	if value := os.Getenv(envShutdownTimeout); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return AppConfig{}, errors.Errorf("invalid %s %q: must be a positive duration, e.g. \"30s\"", envShutdownTimeout, value)
+		}
+		cfg.ShutdownTimeout = timeout
+	}
+	return cfg, nil
+}
+
 func fetchEtcdConfig(cfg *AppConfig) error {
 	if cfg.Vulcand == nil || cfg.Vulcand.Etcd == nil {
 		return errors.New("a valid etcd.Config{} and vulcand.Config{} config is required")
@@ -84,3 +84,125 @@ func (s *ConfigSuite) TestFetchEtcdConfig(c *C) {
 	c.Assert(cfg.ProtectedAPIHost, Equals, "prot_host")
 	c.Assert(cfg.ProtectedAPIURL, Equals, "prot_url")
 }
+
+// setEnv sets the environment variables and returns a function that restores their previous values.
+func setEnv(vars map[string]string) func() {
+	previous := make(map[string]*string, len(vars))
+	for name, value := range vars {
+		if old, ok := os.LookupEnv(name); ok {
+			previous[name] = &old
+		} else {
+			previous[name] = nil
+		}
+		os.Setenv(name, value)
+	}
+	return func() {
+		for name, old := range previous {
+			if old == nil {
+				os.Unsetenv(name)
+			} else {
+				os.Setenv(name, *old)
+			}
+		}
+	}
+}
+
+func (s *ConfigSuite) TestAppConfigFromEnv(c *C) {
+	defer setEnv(map[string]string{
+		"SCROLL_NAME":               "messages",
+		"SCROLL_VERSION":            "1.2.3",
+		"SCROLL_LISTEN_IP":          "127.0.0.1",
+		"SCROLL_LISTEN_PORT":        "8080",
+		"SCROLL_PUBLIC_API_HOST":    "api.example.com",
+		"SCROLL_PROTECTED_API_HOST": "api.internal",
+		"SCROLL_REGISTER":           "true",
+		"SCROLL_VULCAND_NAMESPACE":  "/vulcand-staging",
+		"SCROLL_SHUTDOWN_TIMEOUT":   "30s",
+	})()
+
+	cfg, err := AppConfigFromEnv()
+
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Name, Equals, "messages")
+	c.Assert(cfg.Version, Equals, "1.2.3")
+	c.Assert(cfg.ListenIP, Equals, "127.0.0.1")
+	c.Assert(cfg.ListenPort, Equals, 8080)
+	c.Assert(cfg.PublicAPIHost, Equals, "api.example.com")
+	c.Assert(cfg.ProtectedAPIHost, Equals, "api.internal")
+	c.Assert(cfg.DisableRegistration, Equals, false)
+	c.Assert(cfg.Vulcand, DeepEquals, &vulcand.Config{Namespace: "/vulcand-staging"})
+	c.Assert(cfg.ShutdownTimeout, Equals, 30*time.Second)
+}
+
+func (s *ConfigSuite) TestAppConfigFromEnvUnset(c *C) {
+	vars := []string{"SCROLL_NAME", "SCROLL_LISTEN_IP", "SCROLL_LISTEN_PORT", "SCROLL_REGISTER", "SCROLL_VULCAND_NAMESPACE", "SCROLL_SHUTDOWN_TIMEOUT"}
+	unset := make(map[string]string, len(vars))
+	for _, name := range vars {
+		unset[name] = ""
+	}
+	defer setEnv(unset)()
+
+	cfg, err := AppConfigFromEnv()
+
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Name, Equals, "")
+	c.Assert(cfg.ListenPort, Equals, 0)
+	c.Assert(cfg.DisableRegistration, Equals, false)
+	c.Assert(cfg.Vulcand, IsNil)
+	c.Assert(cfg.ShutdownTimeout, Equals, time.Duration(0))
+}
+
+func (s *ConfigSuite) TestAppConfigFromEnvRegister(c *C) {
+	for i, tc := range []struct {
+		value    string
+		disabled bool
+	}{
+		{"true", false},
+		{"1", false},
+		{"false", true},
+		{"0", true},
+	} {
+		c.Logf("Test case #%d", i)
+		restore := setEnv(map[string]string{"SCROLL_REGISTER": tc.value, "SCROLL_VULCAND_NAMESPACE": ""})
+
+		cfg, err := AppConfigFromEnv()
+
+		restore()
+		c.Assert(err, IsNil)
+		c.Assert(cfg.DisableRegistration, Equals, tc.disabled)
+		c.Assert(cfg.Vulcand, IsNil)
+	}
+}
+
+func (s *ConfigSuite) TestAppConfigFromEnvInvalid(c *C) {
+	for i, tc := range []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{"SCROLL_LISTEN_PORT", "http", `invalid SCROLL_LISTEN_PORT "http": must be a port number`},
+		{"SCROLL_LISTEN_PORT", "70000", `invalid SCROLL_LISTEN_PORT "70000": must be a port number`},
+		{"SCROLL_LISTEN_IP", "localhost", `invalid SCROLL_LISTEN_IP "localhost": must be an IP address`},
+		{"SCROLL_REGISTER", "yes", `invalid SCROLL_REGISTER "yes": must be a boolean`},
+		{"SCROLL_SHUTDOWN_TIMEOUT", "30", `invalid SCROLL_SHUTDOWN_TIMEOUT "30": must be a positive duration, .*`},
+	} {
+		c.Logf("Test case #%d", i)
+		restore := setEnv(map[string]string{tc.name: tc.value})
+
+		_, err := AppConfigFromEnv()
+
+		restore()
+		c.Assert(err, ErrorMatches, tc.err)
+	}
+}
+
+func (s *ConfigSuite) TestAppConfigFromEnvNamespaceWithoutRegistration(c *C) {
+	defer setEnv(map[string]string{
+		"SCROLL_REGISTER":          "false",
+		"SCROLL_VULCAND_NAMESPACE": "/vulcand-staging",
+	})()
+
+	_, err := AppConfigFromEnv()
+
+	c.Assert(err, ErrorMatches, `SCROLL_VULCAND_NAMESPACE is set but vulcand registration is disabled with SCROLL_REGISTER`)
+}
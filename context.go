@@ -0,0 +1,39 @@
+package scroll
+
+import (
+	"context"
+	"net/http"
+)
+
+// ContextKey is a key request-scoped values are stored under with WithValue, e.g. the user an auth
+// middleware has authenticated. Keys are compared by identity, so each key should be created once
+// with NewContextKey and shared by the code that stores and retrieves the value:
+//
+//  var UserKey = scroll.NewContextKey("user")
+//
+// Values stored under distinct keys never collide, even if the keys have the same name.
+type ContextKey struct {
+	name string
+}
+
+// NewContextKey creates a context key, the name is used for debugging only.
+func NewContextKey(name string) *ContextKey {
+	return &ContextKey{name: name}
+}
+
+func (k *ContextKey) String() string {
+	return "scroll context key " + k.name
+}
+
+// WithValue returns a copy of the request whose context carries the value under the key. Middlewares
+// should pass the returned request to the next handler.
+func WithValue(r *http.Request, key *ContextKey, value interface{}) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), key, value))
+}
+
+// ContextValue returns the value stored in the request's context under the key. The boolean is false
+// if no value is stored under the key.
+func ContextValue(r *http.Request, key *ContextKey) (interface{}, bool) {
+	value := r.Context().Value(key)
+	return value, value != nil
+}
@@ -0,0 +1,55 @@
+package scroll
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+)
+
+type ContextSuite struct{}
+
+var _ = Suite(&ContextSuite{})
+
+func (s *ContextSuite) TestValueAcrossMiddleware(c *C) {
+	userKey := NewContextKey("user")
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	app.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, WithValue(r, userKey, "alice"))
+		})
+	})
+	err = app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/me"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			user, ok := ContextValue(r, userKey)
+			if !ok {
+				return nil, NotFoundError{"no user"}
+			}
+			return Response{"user": user, "request_id": RequestIDFromContext(r.Context())}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	request := httptest.NewRequest("GET", "/me", nil)
+	request.Header.Set(RequestIDHeader, "abc-123")
+	recorder := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(recorder, request)
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"user": "alice", "request_id": "abc-123"})
+}
+
+func (s *ContextSuite) TestDistinctKeys(c *C) {
+	key, other := NewContextKey("user"), NewContextKey("user")
+	request := WithValue(httptest.NewRequest("GET", "/", nil), key, "alice")
+
+	value, ok := ContextValue(request, key)
+	c.Assert(ok, Equals, true)
+	c.Assert(value, Equals, "alice")
+
+	_, ok = ContextValue(request, other)
+	c.Assert(ok, Equals, false)
+}
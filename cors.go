@@ -0,0 +1,107 @@
+package scroll
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig is a Cross-Origin Resource Sharing configuration an app can be created with.
+type CORSConfig struct {
+	// Origins allowed to make cross-origin requests, e.g. "https://app.example.com".
+	// An origin "*" allows any origin, it cannot be combined with AllowCredentials.
+	AllowedOrigins []string
+
+	// Methods advertised in response to preflight requests. If not specified,
+	// the methods of the handler's spec are advertised.
+	AllowedMethods []string
+
+	// Request headers advertised in response to preflight requests. If not specified,
+	// the headers requested by the preflight request are allowed.
+	AllowedHeaders []string
+
+	// How long the results of a preflight request can be cached by the client.
+	MaxAge time.Duration
+
+	// Whether the response can be shared when the request's credentials mode is "include".
+	AllowCredentials bool
+}
+
+// validate returns an error if the config would let any origin make requests with credentials.
+func (cfg *CORSConfig) validate() error {
+	if !cfg.AllowCredentials {
+		return nil
+	}
+	for _, allowed := range cfg.AllowedOrigins {
+		if allowed == "*" {
+			return errors.New(`the CORS origin "*" cannot be allowed along with credentials`)
+		}
+	}
+	return nil
+}
+
+// allowedOrigin returns the value of the Access-Control-Allow-Origin header for the request's origin,
+// or an empty string if the origin is not allowed.
+func (cfg *CORSConfig) allowedOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, allowed := range cfg.AllowedOrigins {
+		if allowed == "*" {
+			// Browsers reject the wildcard for requests with credentials, hence the origin is never
+			// reflected for it.
+			return "*"
+		}
+		if allowed == origin {
+			return origin
+		}
+	}
+	return ""
+}
+
+// setOriginHeaders sets the headers common to simple and preflight requests. Returns false if the
+// request's origin is not allowed.
+func (cfg *CORSConfig) setOriginHeaders(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Add("Vary", "Origin")
+	origin := cfg.allowedOrigin(r.Header.Get("Origin"))
+	if origin == "" {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if cfg.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// corsHandler wraps the provided handler adding CORS headers to the responses to allowed origins.
+func corsHandler(cfg *CORSConfig, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg.setOriginHeaders(w, r)
+		handler(w, r)
+	}
+}
+
+// corsPreflightHandler returns a handler that responds to preflight OPTIONS requests for a handler
+// registered for the provided methods.
+func corsPreflightHandler(cfg *CORSConfig, methods []string) http.HandlerFunc {
+	if len(cfg.AllowedMethods) != 0 {
+		methods = cfg.AllowedMethods
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.setOriginHeaders(w, r) {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if len(cfg.AllowedHeaders) != 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+			} else if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+			}
+			if cfg.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,92 @@
+package scroll
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type CORSSuite struct {
+	app *App
+}
+
+var _ = Suite(&CORSSuite{})
+
+func (s *CORSSuite) SetUpTest(c *C) {
+	app, err := NewAppWithConfig(AppConfig{
+		Name: "test",
+		CORS: &CORSConfig{
+			AllowedOrigins:   []string{"https://app.example.com"},
+			AllowedHeaders:   []string{"Content-Type", "Authorization"},
+			MaxAge:           10 * time.Minute,
+			AllowCredentials: true,
+		},
+	})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Methods: []string{"GET", "POST"},
+		Paths:   []string{"/messages"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"message": "OK"}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+	s.app = app
+}
+
+func (s *CORSSuite) serve(method, origin string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, "/messages", nil)
+	request.Header.Set("Origin", origin)
+	recorder := httptest.NewRecorder()
+	s.app.GetHandler().ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (s *CORSSuite) TestPreflight(c *C) {
+	recorder := s.serve("OPTIONS", "https://app.example.com")
+
+	c.Assert(recorder.Code, Equals, http.StatusNoContent)
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Origin"), Equals, "https://app.example.com")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Methods"), Equals, "GET, POST")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Headers"), Equals, "Content-Type, Authorization")
+	c.Assert(recorder.Header().Get("Access-Control-Max-Age"), Equals, "600")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Credentials"), Equals, "true")
+}
+
+func (s *CORSSuite) TestAllowedOrigin(c *C) {
+	recorder := s.serve("GET", "https://app.example.com")
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Origin"), Equals, "https://app.example.com")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Credentials"), Equals, "true")
+}
+
+func (s *CORSSuite) TestDisallowedOrigin(c *C) {
+	recorder := s.serve("GET", "https://evil.example.com")
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Origin"), Equals, "")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Credentials"), Equals, "")
+
+	recorder = s.serve("OPTIONS", "https://evil.example.com")
+
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Origin"), Equals, "")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Methods"), Equals, "")
+}
+
+func (s *CORSSuite) TestWildcardOrigin(c *C) {
+	cfg := &CORSConfig{AllowedOrigins: []string{"*"}}
+	c.Assert(cfg.allowedOrigin("https://any.example.com"), Equals, "*")
+
+	c.Assert(cfg.allowedOrigin(""), Equals, "")
+}
+
+func (s *CORSSuite) TestWildcardOriginWithCredentials(c *C) {
+	cors := &CORSConfig{AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true}
+
+	_, err := NewAppWithConfig(AppConfig{Name: "test", CORS: cors})
+
+	c.Assert(err, ErrorMatches, `the CORS origin "\*" cannot be allowed along with credentials`)
+}
@@ -3,8 +3,21 @@ package scroll
 import (
 	"fmt"
 	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
+// CodedError is implemented by errors that have a stable machine-readable code, e.g. "MISSING_FIELD".
+// The code is included in error responses as "code", so clients can branch on the error type.
+type CodedError interface {
+	error
+	Code() string
+}
+
 type GenericAPIError struct {
 	Reason string
 }
@@ -13,6 +26,10 @@ func (e GenericAPIError) Error() string {
 	return e.Reason
 }
 
+func (e GenericAPIError) Code() string {
+	return "BAD_REQUEST"
+}
+
 type MissingFieldError struct {
 	Field string
 }
@@ -21,6 +38,10 @@ func (e MissingFieldError) Error() string {
 	return fmt.Sprintf("Missing mandatory parameter: %v", e.Field)
 }
 
+func (e MissingFieldError) Code() string {
+	return "MISSING_FIELD"
+}
+
 type InvalidFormatError struct {
 	Field string
 	Value string
@@ -30,6 +51,10 @@ func (e InvalidFormatError) Error() string {
 	return fmt.Sprintf("Invalid format for parameter %v: %v", e.Field, e.Value)
 }
 
+func (e InvalidFormatError) Code() string {
+	return "INVALID_FORMAT"
+}
+
 type InvalidParameterError struct {
 	Field string
 	Value string
@@ -39,6 +64,10 @@ func (e InvalidParameterError) Error() string {
 	return fmt.Sprintf("Invalid parameter: %v %v", e.Field, e.Value)
 }
 
+func (e InvalidParameterError) Code() string {
+	return "INVALID_PARAMETER"
+}
+
 type NotFoundError struct {
 	Description string
 }
@@ -47,6 +76,10 @@ func (e NotFoundError) Error() string {
 	return e.Description
 }
 
+func (e NotFoundError) Code() string {
+	return "NOT_FOUND"
+}
+
 type ConflictError struct {
 	Description string
 }
@@ -55,6 +88,10 @@ func (e ConflictError) Error() string {
 	return e.Description
 }
 
+func (e ConflictError) Code() string {
+	return "CONFLICT"
+}
+
 type UnsafeFieldError struct {
 	Field       string
 	Description string
@@ -64,25 +101,212 @@ func (e UnsafeFieldError) Error() string {
 	return fmt.Sprintf("field %q is unsafe: %v", e.Field, e.Description)
 }
 
+func (e UnsafeFieldError) Code() string {
+	return "UNSAFE_FIELD"
+}
+
 type RateLimitError struct {
 	Description string
+
+	// If set, the client is advised to retry after this long with the `Retry-After` header.
+	RetryAfter time.Duration
 }
 
 func (e RateLimitError) Error() string {
 	return fmt.Sprintf("Rate Limited: %v. Try again later (and slower).", e.Description)
 }
 
+func (e RateLimitError) Code() string {
+	return "RATE_LIMITED"
+}
+
+type ServiceUnavailableError struct {
+	Description string
+
+	// If set, the client is advised to retry after this long with the `Retry-After` header.
+	RetryAfter time.Duration
+}
+
+func (e ServiceUnavailableError) Error() string {
+	return fmt.Sprintf("Service unavailable: %v", e.Description)
+}
+
+func (e ServiceUnavailableError) Code() string {
+	return "SERVICE_UNAVAILABLE"
+}
+
+type InvalidJSONError struct {
+	Description string
+}
+
+func (e InvalidJSONError) Error() string {
+	return fmt.Sprintf("Invalid JSON: %v", e.Description)
+}
+
+func (e InvalidJSONError) Code() string {
+	return "INVALID_JSON"
+}
+
+type ValidationError struct {
+	Description string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("Validation failed: %v", e.Description)
+}
+
+func (e ValidationError) Code() string {
+	return "VALIDATION_FAILED"
+}
+
+// ValidationErrors reports all the fields of a request that failed validation, keyed by field name.
+type ValidationErrors map[string]string
+
+func (e ValidationErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = fmt.Sprintf("%v: %v", field, e[field])
+	}
+	return fmt.Sprintf("Validation failed: %v", strings.Join(messages, "; "))
+}
+
+func (e ValidationErrors) Code() string {
+	return "VALIDATION_FAILED"
+}
+
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf("Request timed out after %v", e.Timeout)
+}
+
+func (e TimeoutError) Code() string {
+	return "TIMEOUT"
+}
+
+type BatchTooLargeError struct {
+	Size int
+	Max  int
+}
+
+func (e BatchTooLargeError) Error() string {
+	return fmt.Sprintf("Batch of %v entries exceeds the maximum of %v", e.Size, e.Max)
+}
+
+func (e BatchTooLargeError) Code() string {
+	return "BATCH_TOO_LARGE"
+}
+
+type UnsupportedMediaTypeError struct {
+	ContentType string
+	Expected    string
+}
+
+func (e UnsupportedMediaTypeError) Error() string {
+	return fmt.Sprintf("Unsupported content type %q, expected %q", e.ContentType, e.Expected)
+}
+
+func (e UnsupportedMediaTypeError) Code() string {
+	return "UNSUPPORTED_MEDIA_TYPE"
+}
+
+type registeredError struct {
+	status    int
+	formatter func(error) Response
+}
+
+var (
+	registeredErrorsMu sync.RWMutex
+	registeredErrors   = map[reflect.Type]registeredError{}
+)
+
+// RegisterError teaches scroll how to reply when a handler returns an error of the same type as sample.
+//
+// The formatter builds the response body for the error; if nil, the body is {"message": err.Error()},
+// see ErrorMessageKey.
+//
+// Errors are matched by their exact type, so an error type can only have one registration: registering
+// the same type again replaces the previous registration. Registered errors take precedence over the
+// errors scroll maps on its own (e.g. `MissingFieldError`), which allows to customize their responses.
+func RegisterError(sample error, status int, formatter func(error) Response) {
+	registeredErrorsMu.Lock()
+	defer registeredErrorsMu.Unlock()
+	registeredErrors[reflect.TypeOf(sample)] = registeredError{status: status, formatter: formatter}
+}
+
 func responseAndStatusFor(err error) (Response, int) {
+	registeredErrorsMu.RLock()
+	re, ok := registeredErrors[reflect.TypeOf(err)]
+	registeredErrorsMu.RUnlock()
+	if ok {
+		if re.formatter == nil {
+			return errorResponse(err), re.status
+		}
+		return re.formatter(err), re.status
+	}
+
 	switch err.(type) {
-	case GenericAPIError, MissingFieldError, InvalidFormatError, InvalidParameterError, UnsafeFieldError:
-		return Response{"message": err.Error()}, http.StatusBadRequest
+	case GenericAPIError, MissingFieldError, InvalidFormatError, InvalidParameterError, UnsafeFieldError, InvalidJSONError:
+		return errorResponse(err), http.StatusBadRequest
+	case ValidationError:
+		return errorResponse(err), http.StatusUnprocessableEntity
+	case ValidationErrors:
+		response := errorResponse(err)
+		response["errors"] = map[string]string(err.(ValidationErrors))
+		return response, http.StatusUnprocessableEntity
 	case NotFoundError:
-		return Response{"message": err.Error()}, http.StatusNotFound
+		return errorResponse(err), http.StatusNotFound
 	case ConflictError:
-		return Response{"message": err.Error()}, http.StatusConflict
+		return errorResponse(err), http.StatusConflict
 	case RateLimitError:
-		return Response{"message": err.Error()}, 429 // temporary until we upgrade to Go 1.6 and can use http.StatusTooManyRequests
+		return errorResponse(err), 429 // temporary until we upgrade to Go 1.6 and can use http.StatusTooManyRequests
+	case ServiceUnavailableError:
+		return errorResponse(err), http.StatusServiceUnavailable
+	case TimeoutError:
+		return errorResponse(err), http.StatusGatewayTimeout
+	case BatchTooLargeError:
+		return errorResponse(err), http.StatusRequestEntityTooLarge
+	case UnsupportedMediaTypeError:
+		return errorResponse(err), http.StatusUnsupportedMediaType
 	default:
-		return Response{"message": "Internal Server Error"}, http.StatusInternalServerError
+		return Response{ErrorMessageKey: "Internal Server Error", "code": "INTERNAL_ERROR"}, http.StatusInternalServerError
+	}
+}
+
+// headersFor returns the headers to reply with along with the response for an error.
+func headersFor(err error) map[string]string {
+	var retryAfter time.Duration
+	switch e := err.(type) {
+	case RateLimitError:
+		retryAfter = e.RetryAfter
+	case ServiceUnavailableError:
+		retryAfter = e.RetryAfter
+	}
+	if retryAfter <= 0 {
+		return nil
+	}
+	// Retry-After is specified in whole seconds, round up so clients do not retry too early.
+	seconds := int64((retryAfter + time.Second - 1) / time.Second)
+	return map[string]string{"Retry-After": strconv.FormatInt(seconds, 10)}
+}
+
+// ErrorMessageKey is the key of the error message in the bodies of error responses, e.g. "error" for
+// clients that expect {"error": "..."}.
+var ErrorMessageKey = "message"
+
+// errorResponse builds the response body for an error: its message and, if the error is a
+// `CodedError`, its code.
+func errorResponse(err error) Response {
+	response := Response{ErrorMessageKey: err.Error()}
+	if coded, ok := err.(CodedError); ok {
+		response["code"] = coded.Code()
 	}
+	return response
 }
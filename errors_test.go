@@ -0,0 +1,170 @@
+package scroll
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type ErrorsSuite struct{}
+
+var _ = Suite(&ErrorsSuite{})
+
+type teapotError struct {
+	Flavor string
+}
+
+func (e teapotError) Error() string {
+	return "I'm a teapot: " + e.Flavor
+}
+
+type quotaError struct{}
+
+func (e quotaError) Error() string {
+	return "quota exceeded"
+}
+
+type lockedError struct{}
+
+func (e lockedError) Error() string {
+	return "account locked"
+}
+
+func (e lockedError) Code() string {
+	return "ACCOUNT_LOCKED"
+}
+
+func (s *ErrorsSuite) TearDownTest(c *C) {
+	registeredErrorsMu.Lock()
+	delete(registeredErrors, reflect.TypeOf(teapotError{}))
+	delete(registeredErrors, reflect.TypeOf(quotaError{}))
+	delete(registeredErrors, reflect.TypeOf(lockedError{}))
+	registeredErrorsMu.Unlock()
+}
+
+func (s *ErrorsSuite) TestRegisteredError(c *C) {
+	RegisterError(teapotError{}, http.StatusTeapot, func(err error) Response {
+		return Response{"message": err.Error(), "flavor": err.(teapotError).Flavor}
+	})
+	RegisterError(quotaError{}, http.StatusForbidden, nil)
+
+	response, status := responseAndStatusFor(teapotError{"earl grey"})
+	c.Assert(status, Equals, http.StatusTeapot)
+	c.Assert(response, DeepEquals, Response{"message": "I'm a teapot: earl grey", "flavor": "earl grey"})
+
+	response, status = responseAndStatusFor(quotaError{})
+	c.Assert(status, Equals, http.StatusForbidden)
+	c.Assert(response, DeepEquals, Response{"message": "quota exceeded"})
+}
+
+func (s *ErrorsSuite) TestRegisteredErrorReplaced(c *C) {
+	RegisterError(quotaError{}, http.StatusForbidden, nil)
+	RegisterError(quotaError{}, http.StatusPaymentRequired, nil)
+
+	_, status := responseAndStatusFor(quotaError{})
+	c.Assert(status, Equals, http.StatusPaymentRequired)
+}
+
+func (s *ErrorsSuite) TestUnregisteredError(c *C) {
+	response, status := responseAndStatusFor(errors.New("kaboom"))
+	c.Assert(status, Equals, http.StatusInternalServerError)
+	c.Assert(response, DeepEquals, Response{"message": "Internal Server Error", "code": "INTERNAL_ERROR"})
+}
+
+func (s *ErrorsSuite) TestBuiltinErrors(c *C) {
+	response, status := responseAndStatusFor(MissingFieldError{"name"})
+	c.Assert(status, Equals, http.StatusBadRequest)
+	c.Assert(response, DeepEquals, Response{"message": "Missing mandatory parameter: name", "code": "MISSING_FIELD"})
+
+	response, status = responseAndStatusFor(UnsafeFieldError{"name", "too long"})
+	c.Assert(status, Equals, http.StatusBadRequest)
+	c.Assert(response, DeepEquals, Response{"message": `field "name" is unsafe: too long`, "code": "UNSAFE_FIELD"})
+}
+
+func (s *ErrorsSuite) TestRegisteredCodedError(c *C) {
+	RegisterError(lockedError{}, http.StatusLocked, nil)
+
+	response, status := responseAndStatusFor(lockedError{})
+	c.Assert(status, Equals, http.StatusLocked)
+	c.Assert(response, DeepEquals, Response{"message": "account locked", "code": "ACCOUNT_LOCKED"})
+}
+
+func (s *ErrorsSuite) TestErrorCodes(c *C) {
+	for i, tc := range []struct {
+		err    error
+		code   string
+		status int
+	}{
+		{MissingFieldError{"name"}, "MISSING_FIELD", http.StatusBadRequest},
+		{InvalidFormatError{"age", "old"}, "INVALID_FORMAT", http.StatusBadRequest},
+		{InvalidJSONError{"unexpected EOF"}, "INVALID_JSON", http.StatusBadRequest},
+		{ValidationError{"recipient is required"}, "VALIDATION_FAILED", http.StatusUnprocessableEntity},
+		{NotFoundError{"no such domain"}, "NOT_FOUND", http.StatusNotFound},
+		{RateLimitError{Description: "too many requests"}, "RATE_LIMITED", 429},
+		{UnsupportedMediaTypeError{"text/plain", "application/json"}, "UNSUPPORTED_MEDIA_TYPE", http.StatusUnsupportedMediaType},
+	} {
+		c.Logf("Test case #%d", i)
+		response, status := responseAndStatusFor(tc.err)
+		c.Assert(status, Equals, tc.status)
+		c.Assert(response["code"], Equals, tc.code)
+		c.Assert(response["message"], Equals, tc.err.Error())
+	}
+}
+
+func (s *ErrorsSuite) TestValidationErrors(c *C) {
+	err := ValidationErrors{"to": "is required", "subject": "is too long"}
+
+	response, status := responseAndStatusFor(err)
+
+	c.Assert(status, Equals, http.StatusUnprocessableEntity)
+	c.Assert(response["errors"], DeepEquals, map[string]string{"to": "is required", "subject": "is too long"})
+	c.Assert(response["message"], Equals, "Validation failed: subject: is too long; to: is required")
+	c.Assert(response["code"], Equals, "VALIDATION_FAILED")
+}
+
+func (s *ErrorsSuite) TestRetryAfter(c *C) {
+	for i, tc := range []struct {
+		err        error
+		status     int
+		retryAfter string
+	}{
+		{RateLimitError{Description: "too many requests", RetryAfter: 30 * time.Second}, 429, "30"},
+		{RateLimitError{Description: "too many requests", RetryAfter: 1500 * time.Millisecond}, 429, "2"},
+		{RateLimitError{Description: "too many requests"}, 429, ""},
+		{ServiceUnavailableError{Description: "maintenance", RetryAfter: time.Minute}, http.StatusServiceUnavailable, "60"},
+	} {
+		c.Logf("Test case #%d", i)
+		recorder := httptest.NewRecorder()
+
+		ReplyError(recorder, tc.err)
+
+		c.Assert(recorder.Code, Equals, tc.status)
+		c.Assert(recorder.Header().Get("Retry-After"), Equals, tc.retryAfter)
+	}
+}
+
+func (s *ErrorsSuite) TestReplyErrorCtx(c *C) {
+	var loggedRequest *http.Request
+	var loggedStatus int
+	var loggedErr error
+	LogRequest = func(r *http.Request, status int, elapsedTime time.Duration, err error) {
+		loggedRequest, loggedStatus, loggedErr = r, status, err
+	}
+	defer func() { LogRequest = logRequest }()
+
+	request := httptest.NewRequest("GET", "/resources/1", nil)
+	recorder := httptest.NewRecorder()
+	err := NotFoundError{"resource not found"}
+
+	ReplyErrorCtx(request, recorder, err)
+
+	c.Assert(recorder.Code, Equals, http.StatusNotFound)
+	c.Assert(loggedRequest, NotNil)
+	c.Assert(loggedRequest.URL.Path, Equals, "/resources/1")
+	c.Assert(loggedStatus, Equals, http.StatusNotFound)
+	c.Assert(loggedErr, Equals, err)
+}
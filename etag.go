@@ -0,0 +1,50 @@
+package scroll
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// etagFor returns a weak ETag for a marshalled response body: the hex encoded first 16 bytes of the
+// body's SHA-256 hash, quoted. It is weak since the body is the same whether it is sent compressed or
+// not, see Spec.Compress.
+func etagFor(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches determines whether the ETag matches the value of an `If-None-Match` header, which is
+// either "*" or a comma separated list of, possibly weak, ETags. ETags are compared weakly.
+func etagMatches(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// applyETag computes the ETag of a successful response to a GET or HEAD request. If the request's
+// `If-None-Match` header matches it, the response is replaced with 304 Not Modified and no body,
+// otherwise with the body the ETag was computed for, so that it is not marshalled again. The ETag
+// header to reply with is returned along with the response and status.
+func applyETag(r *http.Request, response interface{}, status int, escapeHTML bool) (interface{}, int, map[string]string) {
+	if status != http.StatusOK || (r.Method != "GET" && r.Method != "HEAD") {
+		return response, status, nil
+	}
+	body, err := marshal(response, escapeHTML)
+	if err != nil {
+		// Let the reply fail to marshal the response as usual.
+		return response, status, nil
+	}
+	etag := etagFor(body)
+	headers := map[string]string{"ETag": etag}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+		return nil, http.StatusNotModified, headers
+	}
+	return marshalledBody(body), status, headers
+}
@@ -0,0 +1,123 @@
+package scroll
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type ETagSuite struct {
+	app *App
+}
+
+var _ = Suite(&ETagSuite{})
+
+func (s *ETagSuite) SetUpTest(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Methods: []string{"GET", "PUT"},
+		Paths:   []string{"/resources/{id}"},
+		ETag:    true,
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"id": params["id"]}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+	s.app = app
+}
+
+func (s *ETagSuite) serve(method, url, ifNoneMatch string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, url, nil)
+	if ifNoneMatch != "" {
+		request.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	recorder := httptest.NewRecorder()
+	s.app.GetHandler().ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (s *ETagSuite) TestETag(c *C) {
+	recorder := s.serve("GET", "/resources/1", "")
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("ETag"), Equals, etagFor([]byte(`{"id":"1"}`)))
+	c.Assert(recorder.Header().Get("ETag"), Matches, `W/"[0-9a-f]{32}"`)
+	c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"id": "1"})
+}
+
+func (s *ETagSuite) TestNotModified(c *C) {
+	etag := s.serve("GET", "/resources/1", "").Header().Get("ETag")
+
+	for i, ifNoneMatch := range []string{etag, `"other", ` + strings.TrimPrefix(etag, "W/"), "*"} {
+		c.Logf("Test case #%d", i)
+		recorder := s.serve("GET", "/resources/1", ifNoneMatch)
+
+		c.Assert(recorder.Code, Equals, http.StatusNotModified)
+		c.Assert(recorder.Header().Get("ETag"), Equals, etag)
+		c.Assert(recorder.Body.Len(), Equals, 0)
+	}
+}
+
+func (s *ETagSuite) TestModified(c *C) {
+	etag := s.serve("GET", "/resources/1", "").Header().Get("ETag")
+
+	recorder := s.serve("GET", "/resources/2", etag)
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("ETag"), Not(Equals), etag)
+	c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"id": "2"})
+}
+
+func (s *ETagSuite) TestNotGet(c *C) {
+	recorder := s.serve("PUT", "/resources/1", "*")
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("ETag"), Equals, "")
+}
+
+func (s *ETagSuite) TestMarshalledOnce(c *C) {
+	var calls int
+	Marshaler = func(v interface{}) ([]byte, error) {
+		calls++
+		return jsonMarshal(v)
+	}
+	defer func() { Marshaler = jsonMarshal }()
+
+	recorder := s.serve("GET", "/resources/1", "")
+
+	c.Assert(calls, Equals, 1)
+	c.Assert(recorder.Header().Get("ETag"), Equals, etagFor(recorder.Body.Bytes()))
+}
+
+func (s *ETagSuite) TestCompressed(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Methods:         []string{"GET"},
+		Paths:           []string{"/resources/{id}"},
+		ETag:            true,
+		Compress:        true,
+		CompressMinSize: 1,
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"id": params["id"]}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+	identity := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(identity, httptest.NewRequest("GET", "/resources/1", nil))
+	request := httptest.NewRequest("GET", "/resources/1", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	request.Header.Set("If-None-Match", identity.Header().Get("ETag"))
+
+	// When
+	recorder := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(recorder, request)
+
+	// Then the ETag is weak, since the compressed representation is not byte for byte identical.
+	c.Assert(identity.Header().Get("ETag"), Matches, `W/".*"`)
+	c.Assert(recorder.Code, Equals, http.StatusNotModified)
+	c.Assert(recorder.Header().Get("ETag"), Equals, identity.Header().Get("ETag"))
+}
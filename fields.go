@@ -131,6 +131,117 @@ func GetDurationField(r *http.Request, fieldName string) (time.Duration, error)
 	return d, nil
 }
 
+// GetFormVarSafe retrieves the requested form value as a string, allowSet provides input sanitization.
+// Just like GetVarSafe, returns either a `MissingFieldError` or an `UnsafeFieldError` if an error occurs.
+//
+// The value is read from the request's parsed form, which includes both the URL query string and a
+// POST, PUT or PATCH body. If both provide a value, the body value takes precedence.
+func GetFormVarSafe(r *http.Request, variableName string, allowSet AllowSet) (string, error) {
+	return GetStringFieldSafe(r, variableName, allowSet)
+}
+
+// GetIntFormVarSafe retrieves the requested form value as an integer, just like GetFormVarSafe.
+// Returns an `InvalidFormatError` if the value is not an integer.
+func GetIntFormVarSafe(r *http.Request, variableName string, allowSet AllowSet) (int, error) {
+	value, err := GetFormVarSafe(r, variableName, allowSet)
+	if err != nil {
+		return 0, err
+	}
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, InvalidFormatError{variableName, value}
+	}
+	return intValue, nil
+}
+
+// GetBoolFormVarSafe retrieves the requested form value as a boolean, just like GetFormVarSafe. Accepts
+// the values understood by strconv.ParseBool. Returns an `InvalidFormatError` if the value is not a boolean.
+func GetBoolFormVarSafe(r *http.Request, variableName string, allowSet AllowSet) (bool, error) {
+	value, err := GetFormVarSafe(r, variableName, allowSet)
+	if err != nil {
+		return false, err
+	}
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, InvalidFormatError{variableName, value}
+	}
+	return boolValue, nil
+}
+
+// GetQueryVarSafe retrieves the requested query string parameter as a string, allowSet provides
+// input sanitization. If an error occurs, returns either a `MissingFieldError` or an `UnsafeFieldError`.
+func GetQueryVarSafe(r *http.Request, variableName string, allowSet AllowSet) (string, error) {
+	values, ok := r.URL.Query()[variableName]
+	if !ok || len(values) == 0 {
+		return "", MissingFieldError{variableName}
+	}
+
+	err := allowSet.IsSafe(values[0])
+	if err != nil {
+		return "", UnsafeFieldError{variableName, err.Error()}
+	}
+
+	return values[0], nil
+}
+
+// GetIntQueryVar retrieves the requested query string parameter as an integer.
+// If the parameter is missing, returns provided default value.
+// Returns `InvalidFormatError` if the parameter is not an integer.
+func GetIntQueryVar(r *http.Request, variableName string, defaultValue int) (int, error) {
+	values, ok := r.URL.Query()[variableName]
+	if !ok || len(values) == 0 {
+		return defaultValue, nil
+	}
+	intValue, err := strconv.Atoi(values[0])
+	if err != nil {
+		return 0, InvalidFormatError{variableName, values[0]}
+	}
+	return intValue, nil
+}
+
+// GetBoolQueryVar retrieves the requested query string parameter as a boolean. Accepts the values
+// understood by strconv.ParseBool, e.g. "true", "false", "1", "0".
+// If the parameter is missing, returns provided default value.
+// Returns `InvalidFormatError` if the parameter is not a boolean.
+func GetBoolQueryVar(r *http.Request, variableName string, defaultValue bool) (bool, error) {
+	values, ok := r.URL.Query()[variableName]
+	if !ok || len(values) == 0 {
+		return defaultValue, nil
+	}
+	boolValue, err := strconv.ParseBool(values[0])
+	if err != nil {
+		return false, InvalidFormatError{variableName, values[0]}
+	}
+	return boolValue, nil
+}
+
+// ParsePaging retrieves the `limit` and `offset` paging query string parameters.
+//
+// If not provided, limit defaults to `DefaultLimit` and offset defaults to 0. A limit larger than
+// `MaxLimit` is clamped to `MaxLimit`. Returns `InvalidFormatError` if either of the parameters is not
+// an integer and `InvalidParameterError` if limit is not positive or offset is negative.
+func ParsePaging(r *http.Request) (limit, offset int, err error) {
+	limit, err = GetIntQueryVar(r, "limit", DefaultLimit)
+	if err != nil {
+		return 0, 0, err
+	}
+	if limit <= 0 {
+		return 0, 0, InvalidParameterError{"limit", strconv.Itoa(limit)}
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	offset, err = GetIntQueryVar(r, "offset", 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	if offset < 0 {
+		return 0, 0, InvalidParameterError{"offset", strconv.Itoa(offset)}
+	}
+	return limit, offset, nil
+}
+
 func HasField(r *http.Request, fieldName string) bool {
 	if _, ok := r.Form[fieldName]; !ok {
 		return false
@@ -2,7 +2,9 @@ package scroll
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 
 	. "gopkg.in/check.v1"
 )
@@ -90,3 +92,136 @@ func (s *FieldsSuite) TestGetFloatField(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(value, Equals, float64(0.0000001))
 }
+
+func (s *FieldsSuite) TestGetQueryVarSafe(c *C) {
+	request, _ := http.NewRequest("GET", "http://example.com?p=foo", nil)
+
+	// Missing parameter.
+	value, err := GetQueryVarSafe(request, "missing", NewAllowSetBytes("fo", 3))
+	c.Assert(err, Equals, MissingFieldError{"missing"})
+	c.Assert(value, Equals, "")
+
+	// Unsafe value.
+	value, err = GetQueryVarSafe(request, "p", NewAllowSetBytes("f", 3))
+	c.Assert(err, FitsTypeOf, UnsafeFieldError{})
+	c.Assert(value, Equals, "")
+
+	// Success.
+	value, err = GetQueryVarSafe(request, "p", NewAllowSetBytes("fo", 3))
+	c.Assert(err, IsNil)
+	c.Assert(value, Equals, "foo")
+}
+
+func (s *FieldsSuite) TestGetIntQueryVar(c *C) {
+	request, _ := http.NewRequest("GET", "http://example.com?p=42&q=abracadabra", nil)
+
+	// Missing parameter.
+	value, err := GetIntQueryVar(request, "missing", 7)
+	c.Assert(err, IsNil)
+	c.Assert(value, Equals, 7)
+
+	// Invalid value.
+	value, err = GetIntQueryVar(request, "q", 7)
+	c.Assert(err, Equals, InvalidFormatError{"q", "abracadabra"})
+	c.Assert(value, Equals, 0)
+
+	// Success.
+	value, err = GetIntQueryVar(request, "p", 7)
+	c.Assert(err, IsNil)
+	c.Assert(value, Equals, 42)
+}
+
+func (s *FieldsSuite) TestGetBoolQueryVar(c *C) {
+	request, _ := http.NewRequest("GET", "http://example.com?p=true&q=maybe", nil)
+
+	// Missing parameter.
+	value, err := GetBoolQueryVar(request, "missing", true)
+	c.Assert(err, IsNil)
+	c.Assert(value, Equals, true)
+
+	// Invalid value.
+	value, err = GetBoolQueryVar(request, "q", true)
+	c.Assert(err, Equals, InvalidFormatError{"q", "maybe"})
+	c.Assert(value, Equals, false)
+
+	// Success.
+	value, err = GetBoolQueryVar(request, "p", false)
+	c.Assert(err, IsNil)
+	c.Assert(value, Equals, true)
+}
+
+func (s *FieldsSuite) TestParsePaging(c *C) {
+	for i, tc := range []struct {
+		query  string
+		limit  int
+		offset int
+		err    error
+	}{{
+		query: "",
+		limit: DefaultLimit,
+	}, {
+		query:  "limit=10&offset=20",
+		limit:  10,
+		offset: 20,
+	}, {
+		query: "limit=10001",
+		limit: MaxLimit,
+	}, {
+		query: "limit=0",
+		err:   InvalidParameterError{"limit", "0"},
+	}, {
+		query: "limit=ten",
+		err:   InvalidFormatError{"limit", "ten"},
+	}, {
+		query: "offset=-1",
+		err:   InvalidParameterError{"offset", "-1"},
+	}} {
+		c.Logf("Test case #%d", i)
+		request, _ := http.NewRequest("GET", "http://example.com?"+tc.query, nil)
+
+		limit, offset, err := ParsePaging(request)
+
+		c.Assert(err, Equals, tc.err)
+		c.Assert(limit, Equals, tc.limit)
+		c.Assert(offset, Equals, tc.offset)
+	}
+}
+
+func (s *FieldsSuite) TestGetFormVarSafe(c *C) {
+	request := httptest.NewRequest("POST", "/?q=query&page=2&both=query", strings.NewReader("to=bob&count=3&both=body&flag=yes"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c.Assert(parseForm(request), IsNil)
+	digits := NewAllowSetBytes("0123456789", 3)
+	letters := NewAllowSetBytes("abcdefghijklmnopqrstuvwxyz", 10)
+
+	// Body values.
+	to, err := GetFormVarSafe(request, "to", NewAllowSetStrings([]string{"alice", "bob"}))
+	c.Assert(err, IsNil)
+	c.Assert(to, Equals, "bob")
+	count, err := GetIntFormVarSafe(request, "count", digits)
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 3)
+
+	// Query values.
+	q, err := GetFormVarSafe(request, "q", letters)
+	c.Assert(err, IsNil)
+	c.Assert(q, Equals, "query")
+	page, err := GetIntFormVarSafe(request, "page", digits)
+	c.Assert(err, IsNil)
+	c.Assert(page, Equals, 2)
+
+	// Body values take precedence.
+	both, err := GetFormVarSafe(request, "both", letters)
+	c.Assert(err, IsNil)
+	c.Assert(both, Equals, "body")
+
+	// Missing, unsafe and invalid values.
+	_, err = GetFormVarSafe(request, "missing", letters)
+	c.Assert(err, Equals, MissingFieldError{"missing"})
+	_, err = GetIntFormVarSafe(request, "missing", digits)
+	c.Assert(err, Equals, MissingFieldError{"missing"})
+	_, err = GetFormVarSafe(request, "to", NewAllowSetStrings([]string{"alice"}))
+	c.Assert(err, FitsTypeOf, UnsafeFieldError{})
+	_, err = GetBoolFormVarSafe(request, "flag", letters)
+	c.Assert(err, Equals, InvalidFormatError{"flag", "yes"})
+}
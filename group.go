@@ -0,0 +1,43 @@
+package scroll
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Group is a set of handlers served under a common path prefix, e.g. "/v2", that share middlewares.
+type Group struct {
+	app    *App
+	prefix string
+	router *mux.Router
+}
+
+// Group creates a group of handlers served under the provided path prefix.
+func (app *App) Group(prefix string) *Group {
+	router := app.router.PathPrefix(prefix).Subrouter()
+	if app.encodedPath {
+		router.UseEncodedPath()
+	}
+	return &Group{app: app, prefix: prefix, router: router}
+}
+
+// AddHandler registers a handler just like App.AddHandler, the spec's paths are relative to the
+// group's prefix.
+func (g *Group) AddHandler(spec Spec) error {
+	_, err := g.app.addHandler(g.router, g.prefix, spec)
+	return err
+}
+
+// AddRoute registers a handler just like Group.AddHandler, in addition returning the registered route.
+func (g *Group) AddRoute(spec Spec) (*RegisteredRoute, error) {
+	return g.app.addHandler(g.router, g.prefix, spec)
+}
+
+// Use adds a middleware that applies to all the handlers of the group, no matter whether they were
+// added before or after the middleware. A middleware added earlier is executed first.
+//
+// Group middlewares are executed after the app's middlewares added with App.Use.
+func (g *Group) Use(mw func(http.Handler) http.Handler) {
+	g.router.Use(mw)
+}
@@ -0,0 +1,84 @@
+package scroll
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+)
+
+type GroupSuite struct {
+	app *App
+}
+
+var _ = Suite(&GroupSuite{})
+
+func (s *GroupSuite) SetUpTest(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	s.app = app
+}
+
+func (s *GroupSuite) serve(method, url string, header http.Header) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, url, nil)
+	for key := range header {
+		request.Header.Set(key, header.Get(key))
+	}
+	recorder := httptest.NewRecorder()
+	s.app.GetHandler().ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (s *GroupSuite) TestHandlersUnderPrefix(c *C) {
+	v2 := s.app.Group("/v2")
+	err := v2.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/domains"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"handler": "domains"}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+	err = v2.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/domains/{domain}"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"domain": params["domain"]}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	domains := s.serve("GET", "/v2/domains", nil)
+	domain := s.serve("GET", "/v2/domains/example%20com", nil)
+	unprefixed := s.serve("GET", "/domains", nil)
+
+	c.Assert(domains.Code, Equals, http.StatusOK)
+	c.Assert(decodeResponse(c, domains), DeepEquals, Response{"handler": "domains"})
+	c.Assert(domain.Code, Equals, http.StatusOK)
+	c.Assert(decodeResponse(c, domain), DeepEquals, Response{"domain": "example com"})
+	c.Assert(unprefixed.Code, Equals, http.StatusNotFound)
+}
+
+func (s *GroupSuite) TestMiddleware(c *C) {
+	handler := func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+		return Response{}, nil
+	}
+	admin := s.app.Group("/admin")
+	err := admin.AddHandler(Spec{Methods: []string{"GET"}, Paths: []string{"/users"}, Handler: handler})
+	c.Assert(err, IsNil)
+	admin.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				ReplyError(w, GenericAPIError{"Unauthorized"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	err = s.app.AddHandler(Spec{Methods: []string{"GET"}, Paths: []string{"/public"}, Handler: handler})
+	c.Assert(err, IsNil)
+
+	c.Assert(s.serve("GET", "/admin/users", nil).Code, Equals, http.StatusBadRequest)
+	c.Assert(s.serve("GET", "/admin/users", http.Header{"Authorization": {"Bearer token"}}).Code, Equals, http.StatusOK)
+	c.Assert(s.serve("GET", "/public", nil).Code, Equals, http.StatusOK)
+}
@@ -1,13 +1,20 @@
 package scroll
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
+	"reflect"
+	"regexp"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -16,8 +23,26 @@ import (
 )
 
 // When Handler or HandlerWithBody is used, this function will be called after every request with a log message.
-// If nil, defaults to github.com/mailgun/log.Infof.
-var LogRequest func(*http.Request, int, time.Duration, error)
+// Defaults to logging via github.com/mailgun/log.Infof.
+var LogRequest func(*http.Request, int, time.Duration, error) = logRequest
+
+// How long clients are advised to wait before retrying a request rejected because the handler's
+// MaxConcurrent limit is reached.
+const concurrencyRetryAfter = time.Second
+
+// Marshaler is used by Reply to encode responses, e.g. it can be replaced with a faster JSON encoder.
+// Defaults to an encoder equivalent to encoding/json.Marshal. A custom Marshaler cannot be combined with
+// AppConfig.DisableHTMLEscaping.
+var Marshaler func(interface{}) ([]byte, error) = jsonMarshal
+
+// OnMarshalError, if set, is called when a response fails to marshal and returns a replacement to reply
+// with instead, e.g. a degraded payload without the offending value. The replacement is replied with the
+// original status. If the replacement fails to marshal too, an error is replied with 500 Internal Server Error.
+var OnMarshalError func(original interface{}, err error) (replacement interface{})
+
+// MultipartMaxMemory is the number of bytes of a multipart/form-data request's files that are kept in memory
+// when its form is parsed, the remainder is stored in temporary files on disk.
+var MultipartMaxMemory int64 = 32 << 20
 
 // Response objects that apps' handlers are advised to return.
 //
@@ -26,35 +51,123 @@ var LogRequest func(*http.Request, int, time.Duration, error)
 //  Response{"message": "OK"}
 type Response map[string]interface{}
 
+// NoContent can be returned by handlers to reply with 204 No Content and an empty body.
+var NoContent = &noContent{}
+
+type noContent struct{}
+
+// StatusResponse is a response with a success status other than 200 OK, see WithStatus.
+type StatusResponse struct {
+	Status int
+	Body   interface{}
+}
+
+// WithStatus can be returned by handlers to reply with the provided status instead of 200 OK,
+// e.g. 201 Created along with a `Location` header set on the http.ResponseWriter.
+func WithStatus(status int, body interface{}) StatusResponse {
+	return StatusResponse{Status: status, Body: body}
+}
+
 // Represents handler's specification.
 type Spec struct {
-	// List of HTTP methods the handler should match.
+	// List of HTTP methods the handler should match. If GET is listed, HEAD requests are matched as well
+	// unless DisableAutoHead is set: the handler is run, but the body it writes is discarded. If no methods
+	// are listed, the handler matches any method.
 	Methods []string
 
+	// Do not match HEAD requests automatically when GET is listed in Methods.
+	DisableAutoHead bool
+
 	// List of paths the handler should match. A separate handler will be registered for each one of them.
 	Paths []string
 
 	// Key/value pairs of specific HTTP headers the handler should match (e.g. Content-Type).
 	Headers []string
 
+	// Key/value pairs of query parameters the handler should match, e.g. "type", "fuzzy". Values may
+	// contain variables just like paths, e.g. "page", "{page:[0-9]+}".
+	Queries []string
+
 	// A handler function to use. Just one of these should be provided.
-	RawHandler      http.HandlerFunc
-	Handler         HandlerFunc
-	HandlerWithBody HandlerWithBodyFunc
+	RawHandler         http.HandlerFunc
+	Handler            HandlerFunc
+	HandlerWithBody    HandlerWithBodyFunc
+	HandlerWithContext HandlerWithContextFunc
 
-	// Unique identifier used when emitting performance metrics for the handler.
+	// Unique identifier used when emitting performance metrics for the handler. If not specified,
+	// the path template of the matched route is used, e.g. "/users/{id}".
 	MetricName string
 
+	// Optional route name that URLs can be built for with App.URL, e.g. for `Location` headers.
+	// If several paths are listed, the name refers to the first one.
+	Name string
+
 	// Controls the handler's accessibility via vulcan (public or protected). If not specified, public is assumed.
+	// Public handlers are routed via AppConfig.PublicAPIHost and protected ones via AppConfig.ProtectedAPIHost,
+	// registering a protected handler fails if the latter is not configured.
 	Scope Scope
 
 	// Vulcan middlewares to register with the handler. When registering, middlewares are assigned priorities
 	// according to their positions in the list: a middleware that appears in the list earlier is executed first.
+	// If any middleware has an explicit priority, i.e. neither zero nor vulcand.DefaultMiddlewarePriority, the
+	// priorities are used as is instead, see vulcand.Middleware.
 	Middlewares []vulcand.Middleware
 
+	// Expression that determines whether vulcand retries a failed request on another server, e.g.
+	// "false" disables retries for non-idempotent requests. If not specified, the vulcand default is used.
+	FailoverPredicate string
+
 	// When Handler or HandlerWithBody is used, this function will be called after every request with a log message.
-	// If nil, defaults to github.com/mailgun/log.Infof.
+	// If nil, the request is logged with AppConfig.Logger if configured, otherwise with LogRequest.
 	LogRequest func(r *http.Request, status int, elapsedTime time.Duration, err error)
+
+	// Maximum time the handler is allowed to run. When it is exceeded the request's context is cancelled
+	// and a 504 Gateway Timeout is returned to the client. The handler's writes to the http.ResponseWriter
+	// after that fail with http.ErrHandlerTimeout. If not specified, the handler is not bounded.
+	Timeout time.Duration
+
+	// By default a panic raised by the handler is recovered, logged along with its stack trace and
+	// turned into a 500 response. Set to true to let panics propagate to an outer middleware instead.
+	// Note that when Timeout is set the handler runs on a separate goroutine, so an unrecovered panic
+	// cannot be caught by an outer middleware and terminates the process.
+	DisableRecovery bool
+
+	// Compress the handler's responses with gzip when the client sends `Accept-Encoding: gzip`.
+	// Responses smaller than CompressMinSize bytes are sent uncompressed unless they are flushed, larger
+	// ones are streamed. If CompressMinSize is not specified, DefaultCompressMinSize is used.
+	Compress        bool
+	CompressMinSize int
+
+	// Reject requests with an empty body with 400 Bad Request, instead of passing an empty body to the
+	// handler. Applies to HandlerWithBody only.
+	RequireBody bool
+
+	// Media type requests must be sent with, e.g. "application/json". Requests with a body and another
+	// `Content-Type` are rejected with 415 Unsupported Media Type before their body is read. Parameters
+	// such as charset are ignored.
+	RequireContentType string
+
+	// Set a weak ETag on successful responses to GET requests and reply with 304 Not Modified and no
+	// body when it matches the request's `If-None-Match` header. The ETag is the hex encoded first 16
+	// bytes of the SHA-256 hash of the marshalled body, it is weak since the body may be compressed.
+	ETag bool
+
+	// Encode responses with a json.Encoder writing to the http.ResponseWriter instead of marshalling
+	// them with Marshaler. Note that json.Encoder still encodes the whole response before writing it,
+	// so this does not save memory for large payloads.
+	StreamResponse bool
+
+	// Maximum number of requests the handler processes concurrently, e.g. to protect a downstream
+	// database. Requests in excess are rejected with 503 Service Unavailable and a `Retry-After` header.
+	// The limit is shared by all the spec's paths. If not specified, the concurrency is not limited.
+	MaxConcurrent int
+
+	// If set, the responses to requests with an `Idempotency-Key` header are stored in the app's
+	// AppConfig.IdempotencyStore for this long, and replayed for requests with the same key by the same
+	// caller (see AppConfig.IdempotencyScope) to the same method and path, e.g. to make retries of POST
+	// requests safe. Replayed responses have an `Idempotent-Replayed: true` header, retries with a
+	// different body are rejected with 422. Responses with 5xx statuses are not stored.
+	IdempotencyTTL time.Duration
 }
 
 // Given a map of parameters url decode each parameter
@@ -86,30 +199,9 @@ type HandlerFunc func(http.ResponseWriter, *http.Request, map[string]string) (in
 // implement it themselves: parsing a request's form, formatting a proper JSON response, emitting
 // the request stats, etc.
 func MakeHandler(app *App, fn HandlerFunc, spec Spec) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		var response interface{}
-		var status int
-		var err error
-
-		start := time.Now()
-		if err = parseForm(r); err != nil {
-			err = fmt.Errorf("Failed to parse request form: %v", err)
-			response = Response{"message": err.Error()}
-			status = http.StatusInternalServerError
-		} else {
-			response, err = fn(w, r, DecodeParams(mux.Vars(r)))
-			if err != nil {
-				response, status = responseAndStatusFor(err)
-			} else {
-				status = http.StatusOK
-			}
-		}
-		elapsedTime := time.Since(start)
-		LogRequest(r, status, elapsedTime, err)
-		app.stats.TrackRequest(spec.MetricName, status, elapsedTime)
-
-		Reply(w, response, status)
-	}
+	return makeHandler(app, spec, false, func(w http.ResponseWriter, r *http.Request, params map[string]string, body []byte) (interface{}, error) {
+		return fn(w, r, params)
+	})
 }
 
 // Defines a signature of a handler function, just like HandlerFunc.
@@ -119,42 +211,142 @@ type HandlerWithBodyFunc func(http.ResponseWriter, *http.Request, map[string]str
 
 // Make a handler out of HandlerWithBodyFunc, just like regular MakeHandler function.
 func MakeHandlerWithBody(app *App, fn HandlerWithBodyFunc, spec Spec) http.HandlerFunc {
+	return makeHandler(app, spec, true, func(w http.ResponseWriter, r *http.Request, params map[string]string, body []byte) (interface{}, error) {
+		return fn(w, r, params, body)
+	})
+}
+
+// Defines a signature of a handler function, just like HandlerFunc.
+//
+// In addition to the HandlerFunc the request's context is passed into this function as a 1st parameter,
+// so handlers can honor cancellation, deadlines and request-scoped values.
+type HandlerWithContextFunc func(context.Context, http.ResponseWriter, *http.Request, map[string]string) (interface{}, error)
+
+// Make a handler out of HandlerWithContextFunc, just like regular MakeHandler function.
+func MakeHandlerWithContext(app *App, fn HandlerWithContextFunc, spec Spec) http.HandlerFunc {
+	return makeHandler(app, spec, false, func(w http.ResponseWriter, r *http.Request, params map[string]string, body []byte) (interface{}, error) {
+		return fn(r.Context(), w, r, params)
+	})
+}
+
+// handlerFunc is the common form all kinds of handler functions are adapted to by makeHandler.
+type handlerFunc func(w http.ResponseWriter, r *http.Request, params map[string]string, body []byte) (interface{}, error)
+
+// makeHandler implements the boilerplate shared by all kinds of handlers. If readBody is true the
+// request's body is read and passed to the handler function.
+func makeHandler(app *App, spec Spec, readBody bool, fn handlerFunc) http.HandlerFunc {
+	escapeHTML := !app.Config.DisableHTMLEscaping
+
+	// A semaphore limiting the number of requests the handler function processes concurrently.
+	var sem chan struct{}
+	if spec.MaxConcurrent > 0 {
+		sem = make(chan struct{}, spec.MaxConcurrent)
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		var response interface{}
+		var headers map[string]string
 		var body []byte
 		var status int
 		var err error
 
 		start := time.Now()
+		cw := &countingResponseWriter{ResponseWriter: w}
+		w = cw
+		r = withRequestID(w, r)
+		if spec.RequireContentType != "" && r.ContentLength != 0 {
+			if err = checkContentType(r, spec.RequireContentType); err != nil {
+				response, status = responseAndStatusFor(err)
+				goto end
+			}
+		}
 		if err = parseForm(r); err != nil {
-			err = fmt.Errorf("Failed to parse request form: %v", err)
-			response = Response{"message": err.Error()}
-			status = http.StatusInternalServerError
+			err = GenericAPIError{fmt.Sprintf("Failed to parse request form: %v", err)}
+			response, status = responseAndStatusFor(err)
 			goto end
 		}
 
-		body, err = ioutil.ReadAll(r.Body)
-		if err != nil {
-			err = fmt.Errorf("Failed to read request body: %v", err)
-			response = Response{"message": err.Error()}
-			status = http.StatusInternalServerError
-			goto end
+		if readBody {
+			body, err = ioutil.ReadAll(r.Body)
+			if err != nil {
+				err = GenericAPIError{fmt.Sprintf("Failed to read request body: %v", err)}
+				response, status = responseAndStatusFor(err)
+				goto end
+			}
+			if spec.RequireBody && len(body) == 0 {
+				err = GenericAPIError{"request body required"}
+				response, status = responseAndStatusFor(err)
+				goto end
+			}
 		}
 
-		response, err = fn(w, r, mux.Vars(r), body)
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+			default:
+				err = ServiceUnavailableError{"too many concurrent requests", concurrencyRetryAfter}
+				response, status = responseAndStatusFor(err)
+				headers = headersFor(err)
+				goto end
+			}
+		}
+
+		response, err = callHandler(w, r, spec, func(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+			if sem != nil {
+				// Released once the handler function returns, even if a timeout has been replied.
+				defer func() { <-sem }()
+			}
+			return fn(w, r, DecodeParams(mux.Vars(r)), body)
+		})
 		if err != nil {
 			response, status = responseAndStatusFor(err)
+			headers = headersFor(err)
+		} else if response == NoContent {
+			status = http.StatusNoContent
+		} else if sr, ok := response.(StatusResponse); ok {
+			response, status = sr.Body, sr.Status
 		} else {
 			status = http.StatusOK
 		}
 
 	end:
+		if app.Config.ResponseWrapper != nil && status != http.StatusNoContent {
+			response = app.Config.ResponseWrapper(status, response)
+		}
+		if spec.ETag && err == nil {
+			response, status, headers = applyETag(r, response, status, escapeHTML)
+		}
+
 		elapsedTime := time.Since(start)
-		LogRequest(r, status, elapsedTime, err)
-		app.stats.TrackRequest(spec.MetricName, status, elapsedTime)
+		if spec.StreamResponse {
+			streamReply(w, response, status, headers, escapeHTML)
+		} else {
+			replyWithHeaders(w, response, status, headers, escapeHTML)
+		}
+
+		// The request is logged and tracked once replied to, so that the size of the response is known.
+		app.trackRequest(r, spec, status, elapsedTime, err, cw.written)
+	}
+}
 
-		Reply(w, response, status)
+// trackRequest logs a request replied to by the handler of the spec and tracks it in the app's stats.
+func (app *App) trackRequest(r *http.Request, spec Spec, status int, elapsedTime time.Duration, err error, written int64) {
+	r = withResponseSize(r, written)
+	if spec.LogRequest != nil {
+		spec.LogRequest(r, status, elapsedTime, err)
+	} else {
+		app.logRequest(r, status, elapsedTime, err)
+	}
+	metricName := spec.MetricName
+	if metricName == "" {
+		metricName = RouteTemplate(r)
 	}
+	if r.Context().Err() == context.Canceled {
+		app.stats.TrackCanceledRequest(metricName, elapsedTime)
+	} else {
+		app.stats.TrackRequest(metricName, status, elapsedTime)
+	}
+	app.stats.TrackResponseSize(metricName, written)
 }
 
 // Reply with the provided HTTP response and status code.
@@ -162,30 +354,128 @@ func MakeHandlerWithBody(app *App, fn HandlerWithBodyFunc, spec Spec) http.Handl
 // Response body must be JSON-marshallable, otherwise the response
 // will be "Internal Server Error".
 func Reply(w http.ResponseWriter, response interface{}, status int) {
+	ReplyWithHeaders(w, response, status, nil)
+}
+
+// ReplyWithHeaders replies with the provided HTTP response and status code, just like Reply, in
+// addition setting the provided headers, e.g. `Location` or `Retry-After`.
+//
+// The JSON content type is set unless a `Content-Type` is explicitly provided in headers. A 204 No Content
+// or 304 Not Modified reply has neither a body nor a content type, the response is ignored.
+func ReplyWithHeaders(w http.ResponseWriter, response interface{}, status int, headers map[string]string) {
+	replyWithHeaders(w, response, status, headers, true)
+}
+
+// replyWithHeaders replies just like ReplyWithHeaders. Unless escapeHTML is set, the response is encoded
+// without escaping HTML characters, see AppConfig.DisableHTMLEscaping.
+func replyWithHeaders(w http.ResponseWriter, response interface{}, status int, headers map[string]string, escapeHTML bool) {
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		for key, value := range headers {
+			w.Header().Set(key, value)
+		}
+		w.WriteHeader(status)
+		return
+	}
+
 	// marshal the body of the response
-	marshalledResponse, err := json.Marshal(response)
+	marshalledResponse, err := marshal(response, escapeHTML)
 	if err != nil {
-		marshalledResponse = []byte(fmt.Sprintf(`{"message": "Failed to marshal response: %v %v"}`, response, err))
-		status = http.StatusInternalServerError
-		LogRequest(nil, status, time.Nanosecond, err)
+		marshalledResponse, status = marshalFallback(response, status, err, escapeHTML)
 	}
 
 	// write JSON response
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	for key, value := range headers {
+		w.Header().Set(key, value)
+	}
 	w.WriteHeader(status)
 	w.Write(marshalledResponse)
 }
 
+// streamReply replies just like ReplyWithHeaders, but encodes the response with a json.Encoder
+// writing to the http.ResponseWriter instead of marshalling it with Marshaler. The status is written
+// along with the body, so if the response cannot be encoded the reply falls back to an error, just
+// like with ReplyWithHeaders.
+func streamReply(w http.ResponseWriter, response interface{}, status int, headers map[string]string, escapeHTML bool) {
+	if _, ok := response.(marshalledBody); ok || status == http.StatusNoContent || status == http.StatusNotModified {
+		ReplyWithHeaders(w, response, status, headers)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	for key, value := range headers {
+		w.Header().Set(key, value)
+	}
+	sw := &streamWriter{w: w, status: status}
+	enc := json.NewEncoder(sw)
+	enc.SetEscapeHTML(escapeHTML)
+	if err := enc.Encode(response); err != nil {
+		// json.Encoder only writes a value once it is encoded in full, hence nothing was written yet.
+		marshalledResponse, status := marshalFallback(response, status, err, escapeHTML)
+		w.WriteHeader(status)
+		w.Write(marshalledResponse)
+	}
+}
+
+// marshalFallback returns the body and status to reply with when the response failed to marshal: the
+// replacement provided by OnMarshalError along with the original status if it marshals, otherwise an
+// error message along with 500 Internal Server Error. The marshalling error is logged.
+func marshalFallback(response interface{}, status int, err error, escapeHTML bool) ([]byte, int) {
+	if OnMarshalError != nil {
+		marshalledReplacement, replacementErr := marshal(OnMarshalError(response, err), escapeHTML)
+		if replacementErr == nil {
+			LogRequest(nil, status, time.Nanosecond, err)
+			return marshalledReplacement, status
+		}
+		err = replacementErr
+	}
+	LogRequest(nil, http.StatusInternalServerError, time.Nanosecond, err)
+	return []byte(fmt.Sprintf(`{%s: "Failed to marshal response: %v %v"}`, strconv.Quote(ErrorMessageKey), response, err)), http.StatusInternalServerError
+}
+
+// streamWriter writes the status before the body it writes.
+type streamWriter struct {
+	w           http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.w.WriteHeader(sw.status)
+		sw.wroteHeader = true
+	}
+	// json.Encoder terminates the encoded value with a newline, drop it so that streamed responses
+	// are identical to buffered ones. Compact JSON cannot contain a newline otherwise.
+	n := len(p)
+	if n != 0 && p[n-1] == '\n' {
+		p = p[:n-1]
+	}
+	written, err := sw.w.Write(p)
+	if err == nil && written == len(p) {
+		written = n
+	}
+	return written, err
+}
+
 // ReplyError converts registered error into HTTP response code and writes it back.
 func ReplyError(w http.ResponseWriter, err error) {
 	response, status := responseAndStatusFor(err)
-	Reply(w, response, status)
+	ReplyWithHeaders(w, response, status, headersFor(err))
+}
+
+// ReplyErrorCtx replies with the error just like ReplyError, in addition logging it with LogRequest
+// along with the request, so that the log entry carries the request's method, path and ID.
+func ReplyErrorCtx(r *http.Request, w http.ResponseWriter, err error) {
+	response, status := responseAndStatusFor(err)
+	LogRequest(r, status, time.Nanosecond, err)
+	ReplyWithHeaders(w, response, status, headersFor(err))
 }
 
 // ReplyInternalError logs the error message and replies with a 500 status code.
 func ReplyInternalError(w http.ResponseWriter, message string) {
 	LogRequest(nil, 500, time.Nanosecond, errors.New(message))
-	Reply(w, Response{"message": message}, http.StatusInternalServerError)
+	Reply(w, Response{ErrorMessageKey: message}, http.StatusInternalServerError)
 }
 
 // GetVarSafe is a helper function that returns the requested variable from URI with allowSet
@@ -207,10 +497,203 @@ func GetVarSafe(r *http.Request, variableName string, allowSet AllowSet) (string
 	return variableValue, nil
 }
 
+// GetIntVarSafe returns the requested variable from URI as an integer, just like GetVarSafe.
+// Returns an `InvalidFormatError` if the variable is not an integer.
+func GetIntVarSafe(r *http.Request, variableName string, allowSet AllowSet) (int, error) {
+	value, err := GetVarSafe(r, variableName, allowSet)
+	if err != nil {
+		return 0, err
+	}
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, InvalidFormatError{variableName, value}
+	}
+	return intValue, nil
+}
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// GetUUIDVarSafe returns the requested variable from URI validating it is a UUID in its canonical
+// 8-4-4-4-12 hex form. Returns either a `MissingFieldError` or an `InvalidFormatError`.
+func GetUUIDVarSafe(r *http.Request, variableName string) (string, error) {
+	value, ok := mux.Vars(r)[variableName]
+	if !ok {
+		return "", MissingFieldError{variableName}
+	}
+	if !uuidRegex.MatchString(value) {
+		return "", InvalidFormatError{variableName, value}
+	}
+	return value, nil
+}
+
+// GetTimeVarSafe returns the requested variable from URI parsed as a time in the provided layout,
+// e.g. `time.RFC3339`. Returns either a `MissingFieldError` or an `InvalidFormatError`.
+func GetTimeVarSafe(r *http.Request, variableName string, layout string) (time.Time, error) {
+	value, ok := mux.Vars(r)[variableName]
+	if !ok {
+		return time.Time{}, MissingFieldError{variableName}
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, InvalidFormatError{variableName, value}
+	}
+	return t, nil
+}
+
+// callHandler invokes the provided handler function. If the spec specifies a timeout the function
+// is called with a request whose context carries the deadline, and a `TimeoutError` is returned if
+// the deadline is exceeded before the function returns. The function then writes to a timeoutWriter,
+// so that it cannot write to the response once the timeout has been replied.
+func callHandler(w http.ResponseWriter, r *http.Request, spec Spec, fn func(http.ResponseWriter, *http.Request) (interface{}, error)) (interface{}, error) {
+	if spec.Timeout <= 0 {
+		return invokeHandler(w, r, spec, fn)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), spec.Timeout)
+	defer cancel()
+
+	type result struct {
+		response interface{}
+		err      error
+	}
+	tw := &timeoutWriter{w: w, header: make(http.Header)}
+	done := make(chan result, 1)
+	go func() {
+		response, err := invokeHandler(tw, r.WithContext(ctx), spec, fn)
+		done <- result{response, err}
+	}()
+
+	select {
+	case res := <-done:
+		tw.finish(false)
+		return res.response, res.err
+	case <-ctx.Done():
+		tw.finish(true)
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, TimeoutError{spec.Timeout}
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// timeoutWriter is the http.ResponseWriter of a handler function that runs with a timeout. The handler
+// function sets the headers of its own map, which are copied to the response when it writes to it, and
+// its writes are discarded with http.ErrHandlerTimeout once it is finished.
+type timeoutWriter struct {
+	mutex       sync.Mutex
+	w           http.ResponseWriter
+	header      http.Header
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+	return tw.w.Write(b)
+}
+
+// Flush flushes the underlying writer if it supports flushing, unless the handler timed out.
+func (tw *timeoutWriter) Flush() {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if flusher, ok := tw.w.(http.Flusher); ok && !tw.timedOut {
+		flusher.Flush()
+	}
+}
+
+func (tw *timeoutWriter) writeHeader(status int) {
+	tw.copyHeader()
+	tw.wroteHeader = true
+	tw.w.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) copyHeader() {
+	for name, values := range tw.header {
+		tw.w.Header()[name] = values
+	}
+}
+
+// finish stops the handler function from writing to the response. Unless it timed out, the headers it
+// set without writing the response are copied to the response, so that they are replied along with it.
+func (tw *timeoutWriter) finish(timedOut bool) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if !timedOut && !tw.wroteHeader {
+		tw.copyHeader()
+	}
+	tw.timedOut = true
+}
+
+// invokeHandler calls the provided handler function converting a panic into an error, unless the
+// spec disables recovery.
+func invokeHandler(w http.ResponseWriter, r *http.Request, spec Spec, fn func(http.ResponseWriter, *http.Request) (interface{}, error)) (response interface{}, err error) {
+	if !spec.DisableRecovery {
+		defer func() {
+			if v := recover(); v != nil {
+				response, err = nil, fmt.Errorf("Handler panicked: %v\n%s", v, debug.Stack())
+			}
+		}()
+	}
+	return fn(w, r)
+}
+
+// customMarshaler reports whether Marshaler was replaced with another encoder than the default one.
+func customMarshaler() bool {
+	return Marshaler != nil && reflect.ValueOf(Marshaler).Pointer() != reflect.ValueOf(jsonMarshal).Pointer()
+}
+
+// marshalledBody is a response that is already marshalled, it is replied as is.
+type marshalledBody []byte
+
+// marshal encodes the response with Marshaler, falling back to encoding/json if it is not set. Unless
+// escapeHTML is set, the response is encoded without escaping HTML characters and Marshaler is not used.
+func marshal(response interface{}, escapeHTML bool) ([]byte, error) {
+	if body, ok := response.(marshalledBody); ok {
+		return body, nil
+	}
+	if !escapeHTML {
+		return encodeJSON(response, false)
+	}
+	if Marshaler == nil {
+		return jsonMarshal(response)
+	}
+	return Marshaler(response)
+}
+
+// checkContentType returns `UnsupportedMediaTypeError` unless the request's media type is the expected one.
+func checkContentType(r *http.Request, expected string) error {
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.EqualFold(mediaType, expected) {
+		return UnsupportedMediaTypeError{contentType, expected}
+	}
+	return nil
+}
+
 // Parse the request data based on its content type.
 func parseForm(r *http.Request) error {
 	if isMultipart(r) == true {
-		return r.ParseMultipartForm(0)
+		return r.ParseMultipartForm(MultipartMaxMemory)
 	} else {
 		return r.ParseForm()
 	}
@@ -218,8 +701,25 @@ func parseForm(r *http.Request) error {
 
 //Log request
 func logRequest(r *http.Request, status int, elapsedTime time.Duration, err error) {
-	log.Infof("Request(Status=%v, Method=%v, Path=%v, Form=%v, Time=%v, Error=%v)",
-		status, r.Method, r.URL, r.Form, elapsedTime, err)
+	if r == nil {
+		log.Infof("Request(Status=%v, Time=%v, Error=%v)", status, elapsedTime, err)
+		return
+	}
+	log.Infof("Request(Status=%v, Method=%v, Path=%v, Route=%v, ClientIP=%v, Form=%v, Time=%v, Bytes=%v, RequestID=%v, Error=%v)",
+		status, r.Method, r.URL, RouteTemplate(r), ClientIP(r), r.Form, elapsedTime, ResponseSizeFromContext(r.Context()),
+		RequestIDFromContext(r.Context()), err)
+}
+
+// RouteTemplate returns the path template of the route that matched the request, e.g. "/users/{id}",
+// so that requests can be grouped by endpoint. Falls back to the request's path if the request was
+// not matched by a route with a path template.
+func RouteTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
 }
 
 // Determine whether the request is multipart/form-data or not.
@@ -0,0 +1,931 @@
+package scroll
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	. "gopkg.in/check.v1"
+)
+
+type HandlerSuite struct {
+	app *App
+}
+
+var _ = Suite(&HandlerSuite{})
+
+func (s *HandlerSuite) SetUpTest(c *C) {
+	router := mux.NewRouter()
+	router.UseEncodedPath()
+	app, err := NewAppWithConfig(AppConfig{Name: "test", Router: router})
+	c.Assert(err, IsNil)
+	s.app = app
+}
+
+// serve sends a request through the app's router and returns the recorded response.
+func (s *HandlerSuite) serve(method, url, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, url, strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	s.app.GetHandler().ServeHTTP(recorder, request)
+	return recorder
+}
+
+func decodeResponse(c *C, recorder *httptest.ResponseRecorder) Response {
+	response := Response{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), &response), IsNil)
+	return response
+}
+
+func (s *HandlerSuite) TestParamsDecodedForAllHandlerKinds(c *C) {
+	err := s.app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/resources/{id}"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"id": params["id"]}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+	err = s.app.AddHandler(Spec{
+		Methods: []string{"POST"},
+		Paths:   []string{"/resources/{id}"},
+		HandlerWithBody: func(w http.ResponseWriter, r *http.Request, params map[string]string, body []byte) (interface{}, error) {
+			return Response{"id": params["id"]}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	get := s.serve("GET", "/resources/hello%20world", "")
+	post := s.serve("POST", "/resources/hello%20world", "{}")
+
+	c.Assert(get.Code, Equals, http.StatusOK)
+	c.Assert(post.Code, Equals, http.StatusOK)
+	c.Assert(decodeResponse(c, get)["id"], Equals, "hello world")
+	c.Assert(decodeResponse(c, post)["id"], Equals, "hello world")
+}
+
+func (s *HandlerSuite) TestDefaultLogRequest(c *C) {
+	c.Assert(LogRequest, NotNil)
+
+	app := &App{stats: newAppStats(nil, "")}
+	handler := MakeHandler(app, func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+		return Response{"message": "OK"}, nil
+	}, Spec{})
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "/", nil))
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+}
+
+func (s *HandlerSuite) TestReplyMarshalFailureWithoutRequest(c *C) {
+	recorder := httptest.NewRecorder()
+
+	Reply(recorder, Response{"bad": make(chan int)}, http.StatusOK)
+
+	c.Assert(recorder.Code, Equals, http.StatusInternalServerError)
+}
+
+type testContextKey struct{}
+
+func (s *HandlerSuite) TestHandlerWithContext(c *C) {
+	err := s.app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/resources/{id}"},
+		HandlerWithContext: func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"id": params["id"], "value": ctx.Value(testContextKey{})}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	request := httptest.NewRequest("GET", "/resources/1", nil)
+	request = request.WithContext(context.WithValue(request.Context(), testContextKey{}, "foo"))
+	recorder := httptest.NewRecorder()
+	s.app.GetHandler().ServeHTTP(recorder, request)
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"id": "1", "value": "foo"})
+}
+
+func (s *HandlerSuite) TestTimeout(c *C) {
+	var loggedStatus int
+	var loggedErr error
+	LogRequest = func(r *http.Request, status int, elapsedTime time.Duration, err error) {
+		loggedStatus, loggedErr = status, err
+	}
+	defer func() { LogRequest = logRequest }()
+
+	err := s.app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/slow"},
+		Timeout: 10 * time.Millisecond,
+		HandlerWithContext: func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+	c.Assert(err, IsNil)
+	err = s.app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/fast"},
+		Timeout: time.Second,
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"message": "OK"}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	recorder := s.serve("GET", "/slow", "")
+	c.Assert(recorder.Code, Equals, http.StatusGatewayTimeout)
+	c.Assert(loggedStatus, Equals, http.StatusGatewayTimeout)
+	c.Assert(loggedErr, Equals, TimeoutError{10 * time.Millisecond})
+
+	recorder = s.serve("GET", "/fast", "")
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+}
+
+func (s *HandlerSuite) TestTimeoutDiscardsLateWrites(c *C) {
+	timedOut := make(chan struct{})
+	written := make(chan error, 1)
+	err := s.app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/late"},
+		Timeout: 10 * time.Millisecond,
+		HandlerWithContext: func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			<-timedOut
+			w.Header().Set("X-Late", "true")
+			_, err := w.Write([]byte("late"))
+			written <- err
+			return nil, nil
+		},
+	})
+	c.Assert(err, IsNil)
+	err = s.app.AddHandler(Spec{
+		Methods: []string{"POST"},
+		Paths:   []string{"/created"},
+		Timeout: time.Second,
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			w.Header().Set("Location", "/created/1")
+			return WithStatus(http.StatusCreated, Response{}), nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	// When
+	recorder := s.serve("GET", "/late", "")
+	close(timedOut)
+
+	// Then
+	c.Assert(<-written, Equals, http.ErrHandlerTimeout)
+	c.Assert(recorder.Code, Equals, http.StatusGatewayTimeout)
+	c.Assert(recorder.Header().Get("X-Late"), Equals, "")
+	c.Assert(recorder.Body.String(), Not(Matches), ".*late.*")
+
+	// Headers set by handlers that return in time are replied.
+	recorder = s.serve("POST", "/created", "")
+	c.Assert(recorder.Code, Equals, http.StatusCreated)
+	c.Assert(recorder.Header().Get("Location"), Equals, "/created/1")
+}
+
+func (s *HandlerSuite) TestRouteTemplateLogged(c *C) {
+	var loggedRoute string
+	LogRequest = func(r *http.Request, status int, elapsedTime time.Duration, err error) {
+		loggedRoute = RouteTemplate(r)
+	}
+	defer func() { LogRequest = logRequest }()
+	s.app.stats.prom = newPromCollector()
+
+	err := s.app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/users/{id}"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	recorder := s.serve("GET", "/users/12345", "")
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(loggedRoute, Equals, "/users/{id}")
+	c.Assert(s.app.stats.prom.handlers["/users/{id}"].counts[http.StatusOK], Equals, uint64(1))
+	c.Assert(RouteTemplate(httptest.NewRequest("GET", "/users/12345", nil)), Equals, "/users/12345")
+}
+
+func (s *HandlerSuite) TestMaxConcurrent(c *C) {
+	const limit = 2
+	started := make(chan struct{})
+	release := make(chan struct{})
+	err := s.app.AddHandler(Spec{
+		Methods:       []string{"GET"},
+		Paths:         []string{"/blocking"},
+		MaxConcurrent: limit,
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			started <- struct{}{}
+			<-release
+			return Response{"message": "OK"}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	codes := make(chan int, limit)
+	for i := 0; i < limit; i++ {
+		go func() { codes <- s.serve("GET", "/blocking", "").Code }()
+		<-started
+	}
+
+	rejected := s.serve("GET", "/blocking", "")
+	c.Assert(rejected.Code, Equals, http.StatusServiceUnavailable)
+	c.Assert(rejected.Header().Get("Retry-After"), Equals, "1")
+	c.Assert(decodeResponse(c, rejected)["code"], Equals, "SERVICE_UNAVAILABLE")
+
+	close(release)
+	for i := 0; i < limit; i++ {
+		c.Assert(<-codes, Equals, http.StatusOK)
+	}
+
+	// Once the running requests are done the handler accepts requests again.
+	go func() { <-started }()
+	c.Assert(s.serve("GET", "/blocking", "").Code, Equals, http.StatusOK)
+}
+
+func (s *HandlerSuite) TestMultipartFormInMemory(c *C) {
+	var inMemory bool
+	err := s.app.AddHandler(Spec{
+		Methods: []string{"POST"},
+		Paths:   []string{"/upload"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			file, _, err := r.FormFile("attachment")
+			if err != nil {
+				return nil, err
+			}
+			defer file.Close()
+			_, onDisk := file.(*os.File)
+			inMemory = !onDisk
+			return Response{"subject": r.FormValue("subject")}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	c.Assert(writer.WriteField("subject", "hello"), IsNil)
+	part, err := writer.CreateFormFile("attachment", "hello.txt")
+	c.Assert(err, IsNil)
+	_, err = part.Write([]byte("hello world"))
+	c.Assert(err, IsNil)
+	c.Assert(writer.Close(), IsNil)
+
+	request := httptest.NewRequest("POST", "/upload", &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	recorder := httptest.NewRecorder()
+	s.app.GetHandler().ServeHTTP(recorder, request)
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"subject": "hello"})
+	c.Assert(inMemory, Equals, true)
+}
+
+func (s *HandlerSuite) TestStreamResponse(c *C) {
+	response := Response{
+		"items":   []interface{}{map[string]interface{}{"id": 1, "html": "<b>&</b>"}, "multi\nline"},
+		"total":   2,
+		"nothing": nil,
+	}
+	for _, stream := range []bool{false, true} {
+		err := s.app.AddHandler(Spec{
+			Methods:        []string{"GET"},
+			Paths:          []string{fmt.Sprintf("/items/%t", stream)},
+			StreamResponse: stream,
+			Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+				return WithStatus(http.StatusCreated, response), nil
+			},
+		})
+		c.Assert(err, IsNil)
+	}
+
+	buffered := s.serve("GET", "/items/false", "")
+	streamed := s.serve("GET", "/items/true", "")
+
+	c.Assert(streamed.Code, Equals, http.StatusCreated)
+	c.Assert(streamed.Header().Get("Content-Type"), Equals, "application/json; charset=utf-8")
+	c.Assert(streamed.Body.String(), Equals, buffered.Body.String())
+}
+
+func (s *HandlerSuite) TestStreamResponseMarshalError(c *C) {
+	err := s.app.AddHandler(Spec{
+		Methods:        []string{"GET"},
+		Paths:          []string{"/invalid"},
+		StreamResponse: true,
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"fn": func() {}}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	recorder := s.serve("GET", "/invalid", "")
+
+	c.Assert(recorder.Code, Equals, http.StatusInternalServerError)
+	c.Assert(recorder.Body.String(), Matches, `\{"message": "Failed to marshal response: .*"\}`)
+}
+
+func (s *HandlerSuite) TestResponseWrapper(c *C) {
+	app, err := NewAppWithConfig(AppConfig{
+		Name: "test",
+		ResponseWrapper: func(status int, body interface{}) interface{} {
+			return Response{"data": body, "meta": Response{"status": status}}
+		},
+	})
+	c.Assert(err, IsNil)
+	s.app = app
+	err = s.app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/resources/{id}"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			if params["id"] == "missing" {
+				return nil, NotFoundError{"resource not found"}
+			}
+			return Response{"id": params["id"]}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	found := s.serve("GET", "/resources/1", "")
+	missing := s.serve("GET", "/resources/missing", "")
+
+	c.Assert(found.Code, Equals, http.StatusOK)
+	c.Assert(decodeResponse(c, found), DeepEquals, Response{
+		"data": map[string]interface{}{"id": "1"},
+		"meta": map[string]interface{}{"status": float64(http.StatusOK)},
+	})
+	c.Assert(missing.Code, Equals, http.StatusNotFound)
+	c.Assert(decodeResponse(c, missing), DeepEquals, Response{
+		"data": map[string]interface{}{"message": "resource not found", "code": "NOT_FOUND"},
+		"meta": map[string]interface{}{"status": float64(http.StatusNotFound)},
+	})
+}
+
+func (s *HandlerSuite) TestHTMLEscaping(c *C) {
+	for i, tc := range []struct {
+		disableEscaping bool
+		expected        string
+	}{
+		{false, `{"html":"\u003cb\u003eTom \u0026 Jerry\u003c/b\u003e"}`},
+		{true, `{"html":"<b>Tom & Jerry</b>"}`},
+	} {
+		c.Logf("Test case #%d", i)
+		app, err := NewAppWithConfig(AppConfig{Name: "test", DisableHTMLEscaping: tc.disableEscaping})
+		c.Assert(err, IsNil)
+		s.app = app
+		for _, stream := range []bool{false, true} {
+			err = s.app.AddHandler(Spec{
+				Methods:        []string{"GET"},
+				Paths:          []string{fmt.Sprintf("/html/%t", stream)},
+				StreamResponse: stream,
+				Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+					return Response{"html": "<b>Tom & Jerry</b>"}, nil
+				},
+			})
+			c.Assert(err, IsNil)
+		}
+
+		c.Assert(s.serve("GET", "/html/false", "").Body.String(), Equals, tc.expected)
+		c.Assert(s.serve("GET", "/html/true", "").Body.String(), Equals, tc.expected)
+	}
+}
+
+func (s *HandlerSuite) TestHTMLEscapingCustomMarshaler(c *C) {
+	Marshaler = json.Marshal
+	defer func() { Marshaler = jsonMarshal }()
+
+	_, err := NewAppWithConfig(AppConfig{Name: "test", DisableHTMLEscaping: true})
+
+	c.Assert(err, ErrorMatches, "HTML escaping cannot be disabled when a custom Marshaler is used")
+}
+
+func (s *HandlerSuite) TestCanceledRequestStats(c *C) {
+	s.app.stats.prom = newPromCollector()
+	err := s.app.AddHandler(Spec{
+		Methods:    []string{"GET"},
+		Paths:      []string{"/slow"},
+		MetricName: "slow",
+		HandlerWithContext: func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+	c.Assert(err, IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	request := httptest.NewRequest("GET", "/slow", nil).WithContext(ctx)
+	s.app.GetHandler().ServeHTTP(httptest.NewRecorder(), request)
+
+	counts := s.app.stats.prom.handlers["slow"].counts
+	c.Assert(counts[statusClientClosedRequest], Equals, uint64(1))
+	c.Assert(counts[http.StatusInternalServerError], Equals, uint64(0))
+}
+
+func (s *HandlerSuite) TestRequireContentType(c *C) {
+	err := s.app.AddHandler(Spec{
+		Methods:            []string{"PATCH"},
+		Paths:              []string{"/resources/{id}"},
+		RequireContentType: "application/json",
+		HandlerWithBody: func(w http.ResponseWriter, r *http.Request, params map[string]string, body []byte) (interface{}, error) {
+			return Response{"body": string(body)}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	for i, tc := range []struct {
+		contentType string
+		status      int
+	}{
+		{"application/json", http.StatusOK},
+		{"Application/JSON; charset=utf-8", http.StatusOK},
+		{"application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"", http.StatusUnsupportedMediaType},
+	} {
+		c.Logf("Test case #%d", i)
+		request := httptest.NewRequest("PATCH", "/resources/1", strings.NewReader(`{}`))
+		if tc.contentType != "" {
+			request.Header.Set("Content-Type", tc.contentType)
+		}
+		recorder := httptest.NewRecorder()
+		s.app.GetHandler().ServeHTTP(recorder, request)
+
+		c.Assert(recorder.Code, Equals, tc.status)
+		if tc.status == http.StatusOK {
+			c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"body": "{}"})
+		} else {
+			c.Assert(decodeResponse(c, recorder), DeepEquals, Response{
+				"message": fmt.Sprintf("Unsupported content type %q, expected \"application/json\"", tc.contentType),
+				"code":    "UNSUPPORTED_MEDIA_TYPE",
+			})
+		}
+	}
+}
+
+func (s *HandlerSuite) TestRequireContentTypeWithoutBody(c *C) {
+	err := s.app.AddHandler(Spec{
+		Methods:            []string{"GET", "POST"},
+		Paths:              []string{"/resources"},
+		RequireContentType: "application/json",
+		HandlerWithBody: func(w http.ResponseWriter, r *http.Request, params map[string]string, body []byte) (interface{}, error) {
+			return Response{"body": string(body)}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	for i, method := range []string{"GET", "HEAD", "POST"} {
+		c.Logf("Test case #%d", i)
+		recorder := httptest.NewRecorder()
+		s.app.GetHandler().ServeHTTP(recorder, httptest.NewRequest(method, "/resources", nil))
+
+		c.Assert(recorder.Code, Equals, http.StatusOK)
+	}
+}
+
+func (s *HandlerSuite) TestRequireBody(c *C) {
+	var called bool
+	handler := func(w http.ResponseWriter, r *http.Request, params map[string]string, body []byte) (interface{}, error) {
+		called = true
+		return Response{"length": len(body)}, nil
+	}
+	err := s.app.AddHandler(Spec{Methods: []string{"PUT"}, Paths: []string{"/required"}, RequireBody: true, HandlerWithBody: handler})
+	c.Assert(err, IsNil)
+	err = s.app.AddHandler(Spec{Methods: []string{"PUT"}, Paths: []string{"/optional"}, HandlerWithBody: handler})
+	c.Assert(err, IsNil)
+
+	required := s.serve("PUT", "/required", "")
+	c.Assert(required.Code, Equals, http.StatusBadRequest)
+	c.Assert(decodeResponse(c, required), DeepEquals, Response{"message": "request body required", "code": "BAD_REQUEST"})
+	c.Assert(called, Equals, false)
+
+	optional := s.serve("PUT", "/optional", "")
+	c.Assert(optional.Code, Equals, http.StatusOK)
+	c.Assert(decodeResponse(c, optional), DeepEquals, Response{"length": float64(0)})
+	c.Assert(called, Equals, true)
+
+	provided := s.serve("PUT", "/required", "{}")
+	c.Assert(provided.Code, Equals, http.StatusOK)
+	c.Assert(decodeResponse(c, provided), DeepEquals, Response{"length": float64(2)})
+}
+
+// failingReader fails every read, e.g. like the body of a request whose client went away.
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func (s *HandlerSuite) TestRequestReadErrors(c *C) {
+	handler := func(w http.ResponseWriter, r *http.Request, params map[string]string, body []byte) (interface{}, error) {
+		return Response{}, nil
+	}
+	err := s.app.AddHandler(Spec{Methods: []string{"POST"}, Paths: []string{"/form"}, HandlerWithBody: handler})
+	c.Assert(err, IsNil)
+	err = s.app.AddHandler(Spec{Methods: []string{"PUT"}, Paths: []string{"/body"}, HandlerWithBody: handler})
+	c.Assert(err, IsNil)
+
+	form := httptest.NewRequest("POST", "/form", strings.NewReader("a=%zz"))
+	form.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body := httptest.NewRequest("PUT", "/body", failingReader{})
+	for i, tc := range []struct {
+		request *http.Request
+		message string
+	}{
+		{form, `Failed to parse request form: invalid URL escape "%zz"`},
+		{body, "Failed to read request body: connection reset"},
+	} {
+		c.Logf("Test case #%d", i)
+		recorder := httptest.NewRecorder()
+
+		s.app.GetHandler().ServeHTTP(recorder, tc.request)
+
+		c.Assert(recorder.Code, Equals, http.StatusBadRequest)
+		c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"message": tc.message, "code": "BAD_REQUEST"})
+	}
+}
+
+func (s *HandlerSuite) TestResponseSize(c *C) {
+	s.app.stats.prom = newPromCollector()
+	var sizes []int64
+	for _, stream := range []bool{false, true} {
+		err := s.app.AddHandler(Spec{
+			Methods:        []string{"GET"},
+			Paths:          []string{fmt.Sprintf("/messages/%t", stream)},
+			MetricName:     fmt.Sprintf("messages.%t", stream),
+			StreamResponse: stream,
+			LogRequest: func(r *http.Request, status int, elapsedTime time.Duration, err error) {
+				sizes = append(sizes, ResponseSizeFromContext(r.Context()))
+			},
+			Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+				return Response{"items": []string{"a", "b", "c"}}, nil
+			},
+		})
+		c.Assert(err, IsNil)
+	}
+
+	for i, stream := range []bool{false, true} {
+		c.Logf("Test case #%d", i)
+		recorder := s.serve("GET", fmt.Sprintf("/messages/%t", stream), "")
+
+		c.Assert(recorder.Code, Equals, http.StatusOK)
+		marshalled, err := json.Marshal(Response{"items": []string{"a", "b", "c"}})
+		c.Assert(err, IsNil)
+		c.Assert(recorder.Body.Len(), Equals, len(marshalled))
+		c.Assert(sizes[i], Equals, int64(len(marshalled)))
+		c.Assert(s.app.stats.prom.handlers[fmt.Sprintf("messages.%t", stream)].bytes, Equals, uint64(len(marshalled)))
+	}
+}
+
+func (s *HandlerSuite) TestErrorMessageKey(c *C) {
+	ErrorMessageKey = "error"
+	defer func() { ErrorMessageKey = "message" }()
+	for path, err := range map[string]error{"/not-found": NotFoundError{Description: "message"}, "/internal": errors.New("oops")} {
+		err := err
+		c.Assert(s.app.AddHandler(Spec{
+			Methods: []string{"POST"},
+			Paths:   []string{path},
+			Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+				return nil, err
+			},
+		}), IsNil)
+	}
+	c.Assert(s.app.AddHandler(Spec{
+		Methods: []string{"POST"},
+		Paths:   []string{"/invalid"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"fn": func() {}}, nil
+		},
+	}), IsNil)
+
+	invalidForm := httptest.NewRequest("POST", "/internal", strings.NewReader("%zz"))
+	invalidForm.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	formRecorder := httptest.NewRecorder()
+	s.app.GetHandler().ServeHTTP(formRecorder, invalidForm)
+	internalRecorder := httptest.NewRecorder()
+	ReplyInternalError(internalRecorder, "oops")
+
+	for i, recorder := range []*httptest.ResponseRecorder{
+		s.serve("POST", "/not-found", ""),
+		s.serve("POST", "/internal", ""),
+		s.serve("POST", "/invalid", ""),
+		s.serve("POST", "/unknown", ""),
+		s.serve("GET", "/internal", ""),
+		formRecorder,
+		internalRecorder,
+	} {
+		c.Logf("Test case #%d", i)
+		response := decodeResponse(c, recorder)
+		c.Assert(response["error"], NotNil)
+		c.Assert(response["message"], IsNil)
+	}
+}
+
+func (s *HandlerSuite) TestOnMarshalError(c *C) {
+	var marshalErr error
+	OnMarshalError = func(original interface{}, err error) interface{} {
+		marshalErr = err
+		return Response{"message": "degraded", "id": original.(Response)["id"]}
+	}
+	defer func() { OnMarshalError = nil }()
+	for _, stream := range []bool{false, true} {
+		err := s.app.AddHandler(Spec{
+			Methods:        []string{"GET"},
+			Paths:          []string{fmt.Sprintf("/invalid/%t", stream)},
+			StreamResponse: stream,
+			Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+				return WithStatus(http.StatusAccepted, Response{"id": "1", "fn": func() {}}), nil
+			},
+		})
+		c.Assert(err, IsNil)
+	}
+
+	for _, path := range []string{"/invalid/false", "/invalid/true"} {
+		marshalErr = nil
+		recorder := s.serve("GET", path, "")
+
+		c.Assert(recorder.Code, Equals, http.StatusAccepted)
+		c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"message": "degraded", "id": "1"})
+		c.Assert(marshalErr, NotNil)
+	}
+}
+
+func (s *HandlerSuite) TestOnMarshalErrorInvalidReplacement(c *C) {
+	OnMarshalError = func(original interface{}, err error) interface{} {
+		return original
+	}
+	defer func() { OnMarshalError = nil }()
+
+	recorder := httptest.NewRecorder()
+	Reply(recorder, Response{"fn": func() {}}, http.StatusBadRequest)
+
+	c.Assert(recorder.Code, Equals, http.StatusInternalServerError)
+	c.Assert(recorder.Body.String(), Matches, `\{"message": "Failed to marshal response: .*"\}`)
+}
+
+func (s *HandlerSuite) TestPanicRecovery(c *C) {
+	var loggedErr error
+	LogRequest = func(r *http.Request, status int, elapsedTime time.Duration, err error) {
+		loggedErr = err
+	}
+	defer func() { LogRequest = logRequest }()
+
+	handler := func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+		panic("boom")
+	}
+	err := s.app.AddHandler(Spec{Methods: []string{"GET"}, Paths: []string{"/panic"}, Handler: handler})
+	c.Assert(err, IsNil)
+	err = s.app.AddHandler(Spec{Methods: []string{"GET"}, Paths: []string{"/panic-timeout"}, Handler: handler, Timeout: time.Second})
+	c.Assert(err, IsNil)
+	err = s.app.AddHandler(Spec{Methods: []string{"GET"}, Paths: []string{"/propagate"}, Handler: handler, DisableRecovery: true})
+	c.Assert(err, IsNil)
+
+	for _, path := range []string{"/panic", "/panic-timeout"} {
+		recorder := s.serve("GET", path, "")
+		c.Assert(recorder.Code, Equals, http.StatusInternalServerError)
+		c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"message": "Internal Server Error", "code": "INTERNAL_ERROR"})
+		c.Assert(loggedErr, ErrorMatches, "(?s)Handler panicked: boom.*goroutine.*")
+	}
+
+	c.Assert(func() { s.serve("GET", "/propagate", "") }, PanicMatches, "boom")
+}
+
+func (s *HandlerSuite) TestReplyWithHeaders(c *C) {
+	recorder := httptest.NewRecorder()
+
+	ReplyWithHeaders(recorder, Response{"id": "1"}, http.StatusCreated, map[string]string{"Location": "/resources/1"})
+
+	c.Assert(recorder.Code, Equals, http.StatusCreated)
+	c.Assert(recorder.Header().Get("Location"), Equals, "/resources/1")
+	c.Assert(recorder.Header().Get("Content-Type"), Equals, "application/json; charset=utf-8")
+	c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"id": "1"})
+}
+
+func (s *HandlerSuite) TestReplyWithHeadersContentTypeOverride(c *C) {
+	recorder := httptest.NewRecorder()
+
+	ReplyWithHeaders(recorder, Response{"id": "1"}, http.StatusOK, map[string]string{"Content-Type": "application/vnd.api+json"})
+
+	c.Assert(recorder.Header().Get("Content-Type"), Equals, "application/vnd.api+json")
+}
+
+func (s *HandlerSuite) TestReplyWithHeadersMarshalFailure(c *C) {
+	recorder := httptest.NewRecorder()
+
+	ReplyWithHeaders(recorder, Response{"bad": make(chan int)}, http.StatusCreated, map[string]string{"Location": "/resources/1"})
+
+	c.Assert(recorder.Code, Equals, http.StatusInternalServerError)
+	c.Assert(recorder.Header().Get("Content-Type"), Equals, "application/json; charset=utf-8")
+}
+
+func (s *HandlerSuite) TestGetIntVarSafe(c *C) {
+	request := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), map[string]string{"page": "3", "name": "three"})
+	allowSet := NewIntRangeAllowSet(1, 10)
+
+	page, err := GetIntVarSafe(request, "page", allowSet)
+	c.Assert(err, IsNil)
+	c.Assert(page, Equals, 3)
+
+	_, err = GetIntVarSafe(request, "missing", allowSet)
+	c.Assert(err, Equals, MissingFieldError{"missing"})
+
+	_, err = GetIntVarSafe(request, "name", NewAllowSetBytes("abcdefghijklmnopqrstuvwxyz", 10))
+	c.Assert(err, Equals, InvalidFormatError{"name", "three"})
+
+	_, err = GetIntVarSafe(request, "name", allowSet)
+	c.Assert(err, FitsTypeOf, UnsafeFieldError{})
+}
+
+func (s *HandlerSuite) TestGetUUIDVarSafe(c *C) {
+	request := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), map[string]string{
+		"id": "0f8fad5b-d9cb-469f-a165-70867728950e", "bad": "0f8fad5b-d9cb-469f-a165"})
+
+	id, err := GetUUIDVarSafe(request, "id")
+	c.Assert(err, IsNil)
+	c.Assert(id, Equals, "0f8fad5b-d9cb-469f-a165-70867728950e")
+
+	_, err = GetUUIDVarSafe(request, "missing")
+	c.Assert(err, Equals, MissingFieldError{"missing"})
+
+	_, err = GetUUIDVarSafe(request, "bad")
+	c.Assert(err, Equals, InvalidFormatError{"bad", "0f8fad5b-d9cb-469f-a165"})
+}
+
+func (s *HandlerSuite) TestGetTimeVarSafe(c *C) {
+	request := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), map[string]string{
+		"day": "2017-03-14", "bad": "14/03/2017"})
+
+	day, err := GetTimeVarSafe(request, "day", "2006-01-02")
+	c.Assert(err, IsNil)
+	c.Assert(day.Equal(time.Date(2017, 3, 14, 0, 0, 0, 0, time.UTC)), Equals, true)
+
+	_, err = GetTimeVarSafe(request, "missing", "2006-01-02")
+	c.Assert(err, Equals, MissingFieldError{"missing"})
+
+	_, err = GetTimeVarSafe(request, "bad", "2006-01-02")
+	c.Assert(err, Equals, InvalidFormatError{"bad", "14/03/2017"})
+}
+
+func (s *HandlerSuite) TestNoContent(c *C) {
+	err := s.app.AddHandler(Spec{
+		Methods: []string{"DELETE"},
+		Paths:   []string{"/resources/{id}"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return NoContent, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	recorder := s.serve("DELETE", "/resources/1", "")
+
+	c.Assert(recorder.Code, Equals, http.StatusNoContent)
+	c.Assert(recorder.Body.Len(), Equals, 0)
+	c.Assert(recorder.Header().Get("Content-Type"), Equals, "")
+}
+
+func (s *HandlerSuite) TestReplyNoContent(c *C) {
+	recorder := httptest.NewRecorder()
+
+	Reply(recorder, Response{"ignored": true}, http.StatusNoContent)
+
+	c.Assert(recorder.Code, Equals, http.StatusNoContent)
+	c.Assert(recorder.Body.Len(), Equals, 0)
+	c.Assert(recorder.Header().Get("Content-Type"), Equals, "")
+}
+
+func (s *HandlerSuite) TestNilResponse(c *C) {
+	err := s.app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/nil"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return nil, nil
+		},
+	})
+	c.Assert(err, IsNil)
+
+	recorder := s.serve("GET", "/nil", "")
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Body.String(), Equals, "null")
+	c.Assert(recorder.Header().Get("Content-Type"), Equals, "application/json; charset=utf-8")
+}
+
+func (s *HandlerSuite) TestCustomMarshaler(c *C) {
+	var marshalled interface{}
+	Marshaler = func(v interface{}) ([]byte, error) {
+		marshalled = v
+		return []byte(`{"custom":true}`), nil
+	}
+	defer func() { Marshaler = jsonMarshal }()
+	recorder := httptest.NewRecorder()
+
+	Reply(recorder, Response{"message": "OK"}, http.StatusOK)
+
+	c.Assert(marshalled, DeepEquals, Response{"message": "OK"})
+	c.Assert(recorder.Body.String(), Equals, `{"custom":true}`)
+	c.Assert(recorder.Header().Get("Content-Type"), Equals, "application/json; charset=utf-8")
+}
+
+func (s *HandlerSuite) TestWithStatus(c *C) {
+	var tracked []int
+	s.app.stats.prom = newPromCollector()
+	err := s.app.AddHandler(Spec{
+		Methods: []string{"POST"},
+		Paths:   []string{"/resources"},
+		HandlerWithBody: func(w http.ResponseWriter, r *http.Request, params map[string]string, body []byte) (interface{}, error) {
+			w.Header().Set("Location", "/resources/1")
+			return WithStatus(http.StatusCreated, Response{"id": "1"}), nil
+		},
+		MetricName: "create",
+	})
+	c.Assert(err, IsNil)
+	err = s.app.AddHandler(Spec{
+		Methods: []string{"PUT"},
+		Paths:   []string{"/resources/{id}"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return WithStatus(http.StatusAccepted, Response{"message": "Queued"}), nil
+		},
+		MetricName: "update",
+	})
+	c.Assert(err, IsNil)
+
+	created := s.serve("POST", "/resources", "{}")
+	accepted := s.serve("PUT", "/resources/1", "")
+
+	c.Assert(created.Code, Equals, http.StatusCreated)
+	c.Assert(created.Header().Get("Location"), Equals, "/resources/1")
+	c.Assert(decodeResponse(c, created), DeepEquals, Response{"id": "1"})
+	c.Assert(accepted.Code, Equals, http.StatusAccepted)
+	c.Assert(decodeResponse(c, accepted), DeepEquals, Response{"message": "Queued"})
+
+	for _, metricID := range []string{"create", "update"} {
+		for status := range s.app.stats.prom.handlers[metricID].counts {
+			tracked = append(tracked, status)
+		}
+	}
+	c.Assert(tracked, DeepEquals, []int{http.StatusCreated, http.StatusAccepted})
+}
+
+func (s *HandlerSuite) TestValidationErrors(c *C) {
+	err := s.app.AddHandler(Spec{
+		Methods: []string{"POST"},
+		Paths:   []string{"/messages"},
+		HandlerWithBody: func(w http.ResponseWriter, r *http.Request, params map[string]string, body []byte) (interface{}, error) {
+			return nil, ValidationErrors{"to": "is required", "subject": "is too long"}
+		},
+	})
+	c.Assert(err, IsNil)
+
+	recorder := s.serve("POST", "/messages", "{}")
+
+	c.Assert(recorder.Code, Equals, http.StatusUnprocessableEntity)
+	c.Assert(decodeResponse(c, recorder)["errors"], DeepEquals, map[string]interface{}{
+		"to": "is required", "subject": "is too long"})
+}
+
+func (s *HandlerSuite) TestRetryAfter(c *C) {
+	err := s.app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/limited"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return nil, RateLimitError{Description: "too many requests", RetryAfter: 10 * time.Second}
+		},
+	})
+	c.Assert(err, IsNil)
+
+	recorder := s.serve("GET", "/limited", "")
+
+	c.Assert(recorder.Code, Equals, 429)
+	c.Assert(recorder.Header().Get("Retry-After"), Equals, "10")
+}
+
+var benchmarkResponse = func() Response {
+	items := make([]Response, 1000)
+	for i := range items {
+		items[i] = Response{"id": i, "name": "item", "tags": []string{"a", "b", "c"}}
+	}
+	return Response{"items": items}
+}()
+
+func BenchmarkReply(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ReplyWithHeaders(httptest.NewRecorder(), benchmarkResponse, http.StatusOK, nil)
+	}
+}
+
+func BenchmarkStreamReply(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		streamReply(httptest.NewRecorder(), benchmarkResponse, http.StatusOK, nil, true)
+	}
+}
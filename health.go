@@ -0,0 +1,58 @@
+package scroll
+
+import (
+	"net/http"
+	"sync"
+)
+
+// healthChecks is a set of named checks that determine whether an app is ready to serve requests.
+type healthChecks struct {
+	mutex  sync.Mutex
+	checks map[string]func() error
+}
+
+func (hc *healthChecks) register(name string, fn func() error) {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+	if hc.checks == nil {
+		hc.checks = make(map[string]func() error)
+	}
+	hc.checks[name] = fn
+}
+
+// run runs all registered checks and returns the errors of failed checks keyed by their names.
+func (hc *healthChecks) run() map[string]string {
+	hc.mutex.Lock()
+	checks := make(map[string]func() error, len(hc.checks))
+	for name, fn := range hc.checks {
+		checks[name] = fn
+	}
+	hc.mutex.Unlock()
+
+	failed := make(map[string]string)
+	for name, fn := range checks {
+		if err := fn(); err != nil {
+			failed[name] = err.Error()
+		}
+	}
+	return failed
+}
+
+// RegisterHealthCheck registers a named check that is run every time the health check endpoint is
+// requested. If the check returns an error the endpoint responds with 503 Service Unavailable.
+//
+// Registering a check with the same name again replaces the previous check.
+func (app *App) RegisterHealthCheck(name string, fn func() error) {
+	app.healthChecks.register(name, fn)
+}
+
+// handleHealthCheck responds with 200 OK if all registered health checks pass, otherwise it responds
+// with 503 Service Unavailable listing the failed checks.
+func (app *App) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
+	failed := app.healthChecks.run()
+	if len(failed) != 0 {
+		Reply(w, Response{"status": "degraded", "failed": failed}, http.StatusServiceUnavailable)
+		return
+	}
+	Reply(w, Response{"status": "ok"}, http.StatusOK)
+}
@@ -0,0 +1,59 @@
+package scroll
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+)
+
+type HealthSuite struct {
+	app *App
+}
+
+var _ = Suite(&HealthSuite{})
+
+func (s *HealthSuite) SetUpTest(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test", HealthCheckPath: "/healthz"})
+	c.Assert(err, IsNil)
+	s.app = app
+}
+
+func (s *HealthSuite) serve() *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	s.app.GetHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	return recorder
+}
+
+func (s *HealthSuite) TestHealthy(c *C) {
+	s.app.RegisterHealthCheck("db", func() error { return nil })
+
+	recorder := s.serve()
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"status": "ok"})
+}
+
+func (s *HealthSuite) TestDegraded(c *C) {
+	s.app.RegisterHealthCheck("db", func() error { return nil })
+	s.app.RegisterHealthCheck("queue", func() error { return errors.New("connection refused") })
+
+	recorder := s.serve()
+
+	c.Assert(recorder.Code, Equals, http.StatusServiceUnavailable)
+	c.Assert(decodeResponse(c, recorder), DeepEquals, Response{
+		"status": "degraded",
+		"failed": map[string]interface{}{"queue": "connection refused"},
+	})
+}
+
+func (s *HealthSuite) TestDisabled(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+
+	recorder := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+
+	c.Assert(recorder.Code, Equals, http.StatusNotFound)
+}
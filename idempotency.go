@@ -0,0 +1,200 @@
+package scroll
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader is the header clients set to a unique value to safely retry a request, see
+// Spec.IdempotencyTTL.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// StoredResponse is a response stored for an idempotency key.
+type StoredResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+
+	// SHA-256 of the body of the request the response was stored for, retries of the request with a
+	// different body are rejected.
+	RequestHash string
+}
+
+// IdempotencyStore stores the responses of requests with an `Idempotency-Key` header so that they can be
+// replayed for retries of the requests. Implementations must be safe for concurrent use, e.g. to share
+// the stored responses between the instances of an app they can be backed by Redis.
+type IdempotencyStore interface {
+	// Get returns the response stored for the key, or false if there is none or it expired.
+	Get(key string) (StoredResponse, bool)
+
+	// Set stores the response for the key for the provided duration.
+	Set(key string, response StoredResponse, ttl time.Duration)
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore that keeps the responses in memory, hence they are not
+// shared between the instances of an app.
+type MemoryIdempotencyStore struct {
+	mutex   sync.Mutex
+	entries map[string]memoryIdempotencyEntry
+	now     func() time.Time
+}
+
+type memoryIdempotencyEntry struct {
+	response StoredResponse
+	expires  time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory store.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: make(map[string]memoryIdempotencyEntry), now: time.Now}
+}
+
+func (s *MemoryIdempotencyStore) Get(key string) (StoredResponse, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return StoredResponse{}, false
+	}
+	if !s.now().Before(entry.expires) {
+		delete(s.entries, key)
+		return StoredResponse{}, false
+	}
+	return entry.response, true
+}
+
+// Set stores the response for the key, the responses that expired are evicted.
+func (s *MemoryIdempotencyStore) Set(key string, response StoredResponse, ttl time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	for k, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = memoryIdempotencyEntry{response: response, expires: now.Add(ttl)}
+}
+
+// requestTracker logs and tracks a request replied to without calling the handler, see App.trackRequest.
+type requestTracker func(r *http.Request, status int, elapsedTime time.Duration, err error, written int64)
+
+// idempotencyHandler wraps the provided handler storing the responses to requests with an
+// `Idempotency-Key` header in store, and replaying them for requests with the same key by the same caller,
+// as returned by scope, to the same method and path for ttl. Requests that reuse a key with a different
+// body are rejected with 422. Responses with 5xx statuses are not stored so that the requests can be retried.
+//
+// Only the headers set by the handler are stored, the ones set by the wrapping handlers, e.g. CORS headers,
+// and the request ID are set anew for every request. Replies that do not reach the handler are passed to
+// track, unless it is nil.
+//
+// Requests with the same key that arrive before the first one has been responded to are not deduplicated.
+func idempotencyHandler(store IdempotencyStore, scope func(*http.Request) string, ttl time.Duration, track requestTracker,
+	handler http.HandlerFunc) http.HandlerFunc {
+	if scope == nil {
+		scope = basicAuthUsername
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			handler(w, r)
+			return
+		}
+		start := time.Now()
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			ReplyInternalError(w, fmt.Sprintf("Failed to read request body: %v", err))
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(hash[:])
+
+		key := scope(r) + " " + r.Method + " " + r.URL.Path + " " + idempotencyKey
+		if stored, ok := store.Get(key); ok {
+			cw := &countingResponseWriter{ResponseWriter: w}
+			var status int
+			var err error
+			if stored.RequestHash != requestHash {
+				err = ValidationError{
+					Description: fmt.Sprintf("the %s was already used for a request with a different body", IdempotencyKeyHeader)}
+				var response interface{}
+				response, status = responseAndStatusFor(err)
+				r = withRequestID(cw, r)
+				Reply(cw, response, status)
+			} else {
+				status = stored.Status
+				for name, values := range stored.Header {
+					cw.Header()[name] = values
+				}
+				r = withRequestID(cw, r)
+				cw.Header().Set("Idempotent-Replayed", "true")
+				cw.WriteHeader(status)
+				cw.Write(stored.Body)
+			}
+			if track != nil {
+				track(r, status, time.Since(start), err, cw.written)
+			}
+			return
+		}
+
+		// The headers set so far come from the wrapping handlers.
+		preset := make(map[string]bool, len(w.Header()))
+		for name := range w.Header() {
+			preset[name] = true
+		}
+		rw := &recordingResponseWriter{ResponseWriter: w}
+		handler(rw, r)
+		rw.WriteHeader(http.StatusOK)
+		if rw.status < http.StatusInternalServerError {
+			header := make(http.Header, len(rw.header))
+			for name, values := range rw.header {
+				if !preset[name] && name != RequestIDHeader {
+					header[name] = values
+				}
+			}
+			store.Set(key, StoredResponse{Status: rw.status, Header: header, Body: rw.body.Bytes(), RequestHash: requestHash}, ttl)
+		}
+	}
+}
+
+// basicAuthUsername returns the username of the request's basic auth credentials, if any.
+func basicAuthUsername(r *http.Request) string {
+	username, _, _ := r.BasicAuth()
+	return username
+}
+
+// recordingResponseWriter writes the response through while recording it.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	w.header = make(http.Header, len(w.ResponseWriter.Header()))
+	for name, values := range w.ResponseWriter.Header() {
+		w.header[name] = append([]string(nil), values...)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,214 @@
+package scroll
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type IdempotencySuite struct {
+	app   *App
+	store *MemoryIdempotencyStore
+	now   time.Time
+	calls int
+}
+
+var _ = Suite(&IdempotencySuite{})
+
+func (s *IdempotencySuite) SetUpTest(c *C) {
+	s.now = time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	s.store = NewMemoryIdempotencyStore()
+	s.store.now = func() time.Time { return s.now }
+	s.calls = 0
+
+	app, err := NewAppWithConfig(AppConfig{Name: "test", IdempotencyStore: s.store})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Methods:        []string{"POST"},
+		Paths:          []string{"/messages", "/failures"},
+		IdempotencyTTL: time.Minute,
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			s.calls++
+			if r.URL.Path == "/failures" {
+				return nil, ServiceUnavailableError{Description: "try again"}
+			}
+			w.Header().Set("Location", "/messages/1")
+			return WithStatus(http.StatusCreated, Response{"call": s.calls}), nil
+		},
+	})
+	c.Assert(err, IsNil)
+	s.app = app
+}
+
+func (s *IdempotencySuite) post(path, key string) *httptest.ResponseRecorder {
+	return s.postAs("alice", path, key, "")
+}
+
+func (s *IdempotencySuite) postAs(user, path, key, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("POST", path, strings.NewReader(body))
+	request.SetBasicAuth(user, "secret")
+	if key != "" {
+		request.Header.Set(IdempotencyKeyHeader, key)
+	}
+	recorder := httptest.NewRecorder()
+	s.app.GetHandler().ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (s *IdempotencySuite) TestReplay(c *C) {
+	first := s.post("/messages", "key1")
+	c.Assert(first.Code, Equals, http.StatusCreated)
+	c.Assert(decodeResponse(c, first), DeepEquals, Response{"call": float64(1)})
+	c.Assert(first.Header().Get("Idempotent-Replayed"), Equals, "")
+
+	stored, ok := s.store.Get("alice POST /messages key1")
+	c.Assert(ok, Equals, true)
+	c.Assert(stored.Status, Equals, http.StatusCreated)
+
+	// When
+	second := s.post("/messages", "key1")
+
+	// Then
+	c.Assert(s.calls, Equals, 1)
+	c.Assert(second.Code, Equals, http.StatusCreated)
+	c.Assert(second.Header().Get("Location"), Equals, "/messages/1")
+	c.Assert(second.Header().Get("Idempotent-Replayed"), Equals, "true")
+	c.Assert(second.Body.String(), Equals, first.Body.String())
+}
+
+func (s *IdempotencySuite) TestDistinctKeys(c *C) {
+	for i, key := range []string{"", "", "key1", "key2"} {
+		c.Logf("Test case #%d", i)
+		recorder := s.post("/messages", key)
+		c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"call": float64(i + 1)})
+	}
+}
+
+func (s *IdempotencySuite) TestExpiry(c *C) {
+	s.post("/messages", "key1")
+
+	// When
+	s.now = s.now.Add(time.Minute)
+	recorder := s.post("/messages", "key1")
+
+	// Then
+	c.Assert(s.calls, Equals, 2)
+	c.Assert(recorder.Header().Get("Idempotent-Replayed"), Equals, "")
+	c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"call": float64(2)})
+}
+
+func (s *IdempotencySuite) TestServerErrorsNotStored(c *C) {
+	c.Assert(s.post("/failures", "key1").Code, Equals, http.StatusServiceUnavailable)
+
+	recorder := s.post("/failures", "key1")
+
+	c.Assert(s.calls, Equals, 2)
+	c.Assert(recorder.Header().Get("Idempotent-Replayed"), Equals, "")
+	_, ok := s.store.Get("alice POST /failures key1")
+	c.Assert(ok, Equals, false)
+}
+
+func (s *IdempotencySuite) TestCallersScoped(c *C) {
+	s.postAs("alice", "/messages", "key1", "")
+
+	// When
+	recorder := s.postAs("bob", "/messages", "key1", "")
+
+	// Then
+	c.Assert(s.calls, Equals, 2)
+	c.Assert(recorder.Header().Get("Idempotent-Replayed"), Equals, "")
+	c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"call": float64(2)})
+}
+
+func (s *IdempotencySuite) TestScopeHook(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test", IdempotencyStore: s.store,
+		IdempotencyScope: func(r *http.Request) string { return r.Header.Get("X-Account-ID") }})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Methods:        []string{"POST"},
+		Paths:          []string{"/messages"},
+		IdempotencyTTL: time.Minute,
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+	request := httptest.NewRequest("POST", "/messages", nil)
+	request.Header.Set("X-Account-ID", "42")
+	request.Header.Set(IdempotencyKeyHeader, "key1")
+
+	app.GetHandler().ServeHTTP(httptest.NewRecorder(), request)
+
+	_, ok := s.store.Get("42 POST /messages key1")
+	c.Assert(ok, Equals, true)
+}
+
+func (s *IdempotencySuite) TestBodyMismatch(c *C) {
+	first := s.postAs("alice", "/messages", "key1", `{"to": "bob"}`)
+	c.Assert(first.Code, Equals, http.StatusCreated)
+
+	// When
+	recorder := s.postAs("alice", "/messages", "key1", `{"to": "carol"}`)
+
+	// Then
+	c.Assert(s.calls, Equals, 1)
+	c.Assert(recorder.Code, Equals, http.StatusUnprocessableEntity)
+	c.Assert(decodeResponse(c, recorder), DeepEquals,
+		Response{"code": "VALIDATION_FAILED", "message": "Validation failed: the Idempotency-Key was already used for a request with a different body"})
+
+	// The same body is still replayed.
+	c.Assert(s.postAs("alice", "/messages", "key1", `{"to": "bob"}`).Header().Get("Idempotent-Replayed"), Equals, "true")
+}
+
+func (s *IdempotencySuite) TestReplayTracked(c *C) {
+	s.app.stats.prom = newPromCollector()
+	first := s.post("/messages", "key1")
+
+	// When
+	second := s.post("/messages", "key1")
+	mismatch := s.postAs("alice", "/messages", "key1", `{"to": "carol"}`)
+
+	// Then
+	c.Assert(second.Header().Get("Idempotent-Replayed"), Equals, "true")
+	c.Assert(second.Header().Get(RequestIDHeader), Not(Equals), "")
+	c.Assert(second.Header().Get(RequestIDHeader), Not(Equals), first.Header().Get(RequestIDHeader))
+	c.Assert(mismatch.Header().Get(RequestIDHeader), Not(Equals), "")
+	counts := s.app.stats.prom.handlers["/messages"].counts
+	c.Assert(counts[http.StatusCreated], Equals, uint64(2))
+	c.Assert(counts[http.StatusUnprocessableEntity], Equals, uint64(1))
+}
+
+func (s *IdempotencySuite) TestReplayCORS(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test", IdempotencyStore: s.store,
+		CORS: &CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Methods:        []string{"POST"},
+		Paths:          []string{"/messages"},
+		IdempotencyTTL: time.Minute,
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+	request := httptest.NewRequest("POST", "/messages", nil)
+	request.Header.Set("Origin", "https://app.example.com")
+	request.Header.Set(IdempotencyKeyHeader, "key1")
+	first := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(first, request)
+	c.Assert(first.Header().Get("Access-Control-Allow-Origin"), Equals, "https://app.example.com")
+
+	// When
+	request = httptest.NewRequest("POST", "/messages", nil)
+	request.Header.Set(IdempotencyKeyHeader, "key1")
+	recorder := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(recorder, request)
+
+	// Then
+	c.Assert(recorder.Header().Get("Idempotent-Replayed"), Equals, "true")
+	c.Assert(recorder.Header().Get("Access-Control-Allow-Origin"), Equals, "")
+	c.Assert(recorder.Header()["Vary"], DeepEquals, []string{"Origin"})
+}
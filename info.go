@@ -0,0 +1,22 @@
+package scroll
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// handleInfo responds with the app's name, version, uptime since it was run and the Go runtime
+// version, e.g. to verify which build is live.
+func (app *App) handleInfo(w http.ResponseWriter, r *http.Request) {
+	var uptime time.Duration
+	if !app.started.IsZero() {
+		uptime = time.Since(app.started)
+	}
+	Reply(w, Response{
+		"name":           app.Config.Name,
+		"version":        app.Config.Version,
+		"uptime_seconds": uptime.Seconds(),
+		"go_version":     runtime.Version(),
+	}, http.StatusOK)
+}
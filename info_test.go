@@ -0,0 +1,53 @@
+package scroll
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type InfoSuite struct{}
+
+var _ = Suite(&InfoSuite{})
+
+func (s *InfoSuite) TestInfo(c *C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	port := l.Addr().(*net.TCPAddr).Port
+	c.Assert(l.Close(), IsNil)
+	app, err := NewAppWithConfig(AppConfig{
+		Name:       "test",
+		ListenIP:   "127.0.0.1",
+		ListenPort: port,
+		Version:    "1.2.3-abcdef",
+		InfoPath:   "/_info",
+
+		DisableRegistration: true,
+	})
+	c.Assert(err, IsNil)
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.Run() }()
+	defer func() {
+		app.Stop()
+		c.Assert(<-errCh, Equals, http.ErrServerClosed)
+	}()
+	waitForPing(c, app)
+	time.Sleep(10 * time.Millisecond)
+
+	response, err := http.Get(fmt.Sprintf("http://%s:%d/_info", app.Config.ListenIP, app.Config.ListenPort))
+	c.Assert(err, IsNil)
+	defer response.Body.Close()
+	var info map[string]interface{}
+	c.Assert(json.NewDecoder(response.Body).Decode(&info), IsNil)
+
+	c.Assert(response.StatusCode, Equals, http.StatusOK)
+	c.Assert(info["name"], Equals, "test")
+	c.Assert(info["version"], Equals, "1.2.3-abcdef")
+	c.Assert(info["go_version"], Equals, runtime.Version())
+	c.Assert(info["uptime_seconds"].(float64) > 0, Equals, true)
+}
@@ -0,0 +1,53 @@
+package scroll
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// jsonEncoder is a JSON encoder along with the buffer it encodes to, pooled to be reused across requests.
+type jsonEncoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+// Encoders whose buffer grew larger than this are not returned to the pool, so that a few large
+// responses do not keep their memory allocated for good.
+const maxPooledJSONBufferSize = 64 << 10
+
+var jsonEncoderPool = sync.Pool{
+	New: func() interface{} {
+		je := &jsonEncoder{}
+		je.enc = json.NewEncoder(&je.buf)
+		return je
+	},
+}
+
+// jsonMarshal encodes the value just like encoding/json.Marshal, reusing pooled buffers.
+func jsonMarshal(v interface{}) ([]byte, error) {
+	return encodeJSON(v, true)
+}
+
+// encodeJSON encodes the value just like jsonMarshal, escaping HTML characters only if escapeHTML is set.
+func encodeJSON(v interface{}, escapeHTML bool) ([]byte, error) {
+	je := jsonEncoderPool.Get().(*jsonEncoder)
+	defer func() {
+		if je.buf.Cap() > maxPooledJSONBufferSize {
+			return
+		}
+		je.buf.Reset()
+		jsonEncoderPool.Put(je)
+	}()
+
+	je.enc.SetEscapeHTML(escapeHTML)
+	if err := je.enc.Encode(v); err != nil {
+		return nil, err
+	}
+	// The buffer is reused once returned to the pool, so the result must be copied out of it. The
+	// newline json.Encoder terminates the value with is dropped.
+	encoded := je.buf.Bytes()
+	result := make([]byte, len(encoded)-1)
+	copy(result, encoded)
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package scroll
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+type JSONSuite struct{}
+
+var _ = Suite(&JSONSuite{})
+
+func (s *JSONSuite) TestMarshalLikeEncodingJSON(c *C) {
+	for i, v := range []interface{}{
+		nil,
+		"multi\nline <b>&</b>",
+		Response{"id": 1, "tags": []string{"a", "b"}, "nested": Response{"ok": true}},
+	} {
+		c.Logf("Test case #%d", i)
+		expected, err := json.Marshal(v)
+		c.Assert(err, IsNil)
+
+		encoded, err := jsonMarshal(v)
+
+		c.Assert(err, IsNil)
+		c.Assert(string(encoded), Equals, string(expected))
+	}
+}
+
+func (s *JSONSuite) TestMarshalResultNotReused(c *C) {
+	first, err := jsonMarshal(Response{"message": "first"})
+	c.Assert(err, IsNil)
+	_, err = jsonMarshal(Response{"message": "second"})
+	c.Assert(err, IsNil)
+
+	c.Assert(string(first), Equals, `{"message":"first"}`)
+}
+
+func (s *JSONSuite) TestMarshalError(c *C) {
+	_, err := jsonMarshal(Response{"fn": func() {}})
+	c.Assert(err, NotNil)
+
+	encoded, err := jsonMarshal(Response{"message": "OK"})
+	c.Assert(err, IsNil)
+	c.Assert(string(encoded), Equals, `{"message":"OK"}`)
+}
+
+func (s *JSONSuite) TestMarshalLarge(c *C) {
+	large := strings.Repeat("a", 2*maxPooledJSONBufferSize)
+
+	encoded, err := jsonMarshal(large)
+
+	c.Assert(err, IsNil)
+	c.Assert(string(encoded), Equals, `"`+large+`"`)
+	encoded, err = jsonMarshal(Response{"message": "OK"})
+	c.Assert(err, IsNil)
+	c.Assert(string(encoded), Equals, `{"message":"OK"}`)
+}
+
+func BenchmarkJSONMarshal(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		json.Marshal(benchmarkResponse)
+	}
+}
+
+func BenchmarkPooledJSONMarshal(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		jsonMarshal(benchmarkResponse)
+	}
+}
@@ -0,0 +1,40 @@
+package scroll
+
+import (
+	"net/http"
+	"time"
+)
+
+// StructuredLogger is a logger that accepts log entries as key/value pairs, suitable for
+// log aggregation systems.
+type StructuredLogger interface {
+	Log(fields map[string]interface{})
+}
+
+// logRequest logs a handled request with the app's structured logger if one is configured,
+// otherwise with LogRequest.
+func (app *App) logRequest(r *http.Request, status int, elapsedTime time.Duration, err error) {
+	if app.Config.Logger == nil {
+		LogRequest(r, status, elapsedTime, err)
+		return
+	}
+	app.Config.Logger.Log(requestFields(r, status, elapsedTime, err))
+}
+
+// requestFields returns the key/value pairs describing a handled request.
+func requestFields(r *http.Request, status int, elapsedTime time.Duration, err error) map[string]interface{} {
+	fields := map[string]interface{}{
+		"status":      status,
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"route":       RouteTemplate(r),
+		"client_ip":   ClientIP(r),
+		"duration_ms": float64(elapsedTime) / float64(time.Millisecond),
+		"bytes":       ResponseSizeFromContext(r.Context()),
+		"request_id":  RequestIDFromContext(r.Context()),
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	return fields
+}
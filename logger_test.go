@@ -0,0 +1,60 @@
+package scroll
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+)
+
+type LoggerSuite struct{}
+
+var _ = Suite(&LoggerSuite{})
+
+type recordingLogger struct {
+	entries []map[string]interface{}
+}
+
+func (l *recordingLogger) Log(fields map[string]interface{}) {
+	l.entries = append(l.entries, fields)
+}
+
+func (s *LoggerSuite) TestStructuredRequestLog(c *C) {
+	logger := &recordingLogger{}
+	app, err := NewAppWithConfig(AppConfig{Name: "test", Logger: logger})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/fail"},
+		Handler: func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return nil, errors.New("boom")
+		},
+	})
+	c.Assert(err, IsNil)
+
+	request := httptest.NewRequest("GET", "/fail?a=b", nil)
+	request.Header.Set("X-Request-Id", "abc-123")
+	recorder := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(recorder, request)
+
+	c.Assert(logger.entries, HasLen, 1)
+	fields := logger.entries[0]
+	c.Assert(fields["status"], Equals, http.StatusInternalServerError)
+	c.Assert(fields["method"], Equals, "GET")
+	c.Assert(fields["path"], Equals, "/fail")
+	c.Assert(fields["route"], Equals, "/fail")
+	c.Assert(fields["client_ip"], Equals, "192.0.2.1")
+	c.Assert(fields["request_id"], Equals, "abc-123")
+	c.Assert(fields["error"], Equals, "boom")
+	c.Assert(fields["bytes"], Equals, int64(recorder.Body.Len()))
+	_, ok := fields["duration_ms"].(float64)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *LoggerSuite) TestNoErrorField(c *C) {
+	request := httptest.NewRequest("GET", "/", nil)
+	fields := requestFields(request, http.StatusOK, 0, nil)
+	_, ok := fields["error"]
+	c.Assert(ok, Equals, false)
+}
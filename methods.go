@@ -0,0 +1,111 @@
+package scroll
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// allowedMethods returns the methods of the routes that match the request in everything but the method.
+func (app *App) allowedMethods(r *http.Request) []string {
+	var methods []string
+	seen := make(map[string]bool)
+	app.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		var match mux.RouteMatch
+		if !route.Match(r, &match) && match.MatchErr != mux.ErrMethodMismatch {
+			return nil
+		}
+		routeMethods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range routeMethods {
+			if !seen[method] {
+				seen[method] = true
+				methods = append(methods, method)
+			}
+		}
+		return nil
+	})
+	if !seen["OPTIONS"] {
+		methods = append(methods, "OPTIONS")
+	}
+	return methods
+}
+
+// handleMethodNotAllowed replies to requests whose path is registered but not for their method. The
+// methods the path is registered for are listed in the `Allow` header. OPTIONS requests are answered
+// with 204 No Content and the same header.
+func (app *App) handleMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	w.Header().Set("Allow", strings.Join(app.allowedMethods(r), ", "))
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	Reply(w, Response{ErrorMessageKey: "Method Not Allowed", "code": "METHOD_NOT_ALLOWED"}, http.StatusMethodNotAllowed)
+	app.stats.TrackRequest(methodNotAllowedMetricName, http.StatusMethodNotAllowed, time.Since(start))
+}
+
+// withHead returns the methods along with HEAD if they contain GET but not HEAD.
+func withHead(methods []string) []string {
+	var hasGet bool
+	for _, method := range methods {
+		switch method {
+		case "HEAD":
+			return methods
+		case "GET":
+			hasGet = true
+		}
+	}
+	if !hasGet {
+		return methods
+	}
+	return append(methods[:len(methods):len(methods)], "HEAD")
+}
+
+// headHandler serves HEAD requests with the provided handler discarding the body it writes. Unless the
+// handler sets it, the `Content-Length` header is set to the length of the discarded body.
+func headHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "HEAD" {
+			handler(w, r)
+			return
+		}
+		hw := &headResponseWriter{ResponseWriter: w}
+		handler(hw, r)
+		hw.finish()
+	}
+}
+
+// headResponseWriter discards the body and delays writing the header until the handler is done, so
+// that the length of the body is known.
+type headResponseWriter struct {
+	http.ResponseWriter
+	status int
+	length int
+}
+
+func (hw *headResponseWriter) WriteHeader(status int) {
+	if hw.status == 0 {
+		hw.status = status
+	}
+}
+
+func (hw *headResponseWriter) Write(p []byte) (int, error) {
+	hw.WriteHeader(http.StatusOK)
+	hw.length += len(p)
+	return len(p), nil
+}
+
+func (hw *headResponseWriter) finish() {
+	hw.WriteHeader(http.StatusOK)
+	header := hw.ResponseWriter.Header()
+	if header.Get("Content-Length") == "" && hw.status != http.StatusNoContent && hw.status != http.StatusNotModified {
+		header.Set("Content-Length", strconv.Itoa(hw.length))
+	}
+	hw.ResponseWriter.WriteHeader(hw.status)
+}
@@ -0,0 +1,95 @@
+package scroll
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+)
+
+type MethodsSuite struct {
+	app *App
+}
+
+var _ = Suite(&MethodsSuite{})
+
+func (s *MethodsSuite) SetUpTest(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	handler := func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+		return Response{"message": "OK"}, nil
+	}
+	err = app.AddHandler(Spec{Methods: []string{"GET"}, Paths: []string{"/resources/{id}"}, Handler: handler})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{Methods: []string{"PUT", "DELETE"}, Paths: []string{"/resources/{id}"}, Handler: handler})
+	c.Assert(err, IsNil)
+	s.app = app
+}
+
+func (s *MethodsSuite) serve(method, url string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	s.app.GetHandler().ServeHTTP(recorder, httptest.NewRequest(method, url, nil))
+	return recorder
+}
+
+func (s *MethodsSuite) TestMethodNotAllowed(c *C) {
+	s.app.stats.prom = newPromCollector()
+
+	recorder := s.serve("POST", "/resources/1")
+
+	c.Assert(recorder.Code, Equals, http.StatusMethodNotAllowed)
+	c.Assert(recorder.Header().Get("Allow"), Equals, "GET, HEAD, PUT, DELETE, OPTIONS")
+	c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"message": "Method Not Allowed", "code": "METHOD_NOT_ALLOWED"})
+	c.Assert(s.app.stats.prom.handlers[methodNotAllowedMetricName].counts[http.StatusMethodNotAllowed], Equals, uint64(1))
+}
+
+func (s *MethodsSuite) TestOptions(c *C) {
+	recorder := s.serve("OPTIONS", "/resources/1")
+
+	c.Assert(recorder.Code, Equals, http.StatusNoContent)
+	c.Assert(recorder.Header().Get("Allow"), Equals, "GET, HEAD, PUT, DELETE, OPTIONS")
+	c.Assert(recorder.Body.Len(), Equals, 0)
+}
+
+func (s *MethodsSuite) TestNotFound(c *C) {
+	s.app.stats.prom = newPromCollector()
+
+	recorder := s.serve("POST", "/unknown")
+
+	c.Assert(recorder.Code, Equals, http.StatusNotFound)
+	c.Assert(recorder.Header().Get("Content-Type"), Equals, "application/json; charset=utf-8")
+	c.Assert(decodeResponse(c, recorder), DeepEquals, Response{"message": "not found", "code": "NOT_FOUND"})
+	c.Assert(s.app.stats.prom.handlers[notFoundMetricName].counts[http.StatusNotFound], Equals, uint64(1))
+}
+
+func (s *MethodsSuite) TestCustomNotFound(c *C) {
+	s.app.SetNotFoundHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	recorder := s.serve("GET", "/unknown")
+
+	c.Assert(recorder.Code, Equals, http.StatusTeapot)
+}
+
+func (s *MethodsSuite) TestHead(c *C) {
+	recorder := s.serve("HEAD", "/resources/1")
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Body.Len(), Equals, 0)
+	c.Assert(recorder.Header().Get("Content-Type"), Equals, "application/json; charset=utf-8")
+	c.Assert(recorder.Header().Get("Content-Length"), Equals, "16")
+}
+
+func (s *MethodsSuite) TestHeadDisabled(c *C) {
+	handler := func(w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+		return Response{"message": "OK"}, nil
+	}
+	err := s.app.AddHandler(Spec{Methods: []string{"GET"}, Paths: []string{"/no-head"}, Handler: handler, DisableAutoHead: true})
+	c.Assert(err, IsNil)
+
+	recorder := s.serve("HEAD", "/no-head")
+
+	c.Assert(recorder.Code, Equals, http.StatusMethodNotAllowed)
+	c.Assert(recorder.Header().Get("Allow"), Equals, "GET, OPTIONS")
+}
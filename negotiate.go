@@ -0,0 +1,63 @@
+package scroll
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ReplyNegotiated replies with the provided HTTP response and status code in the format preferred
+// by the client according to the request's Accept header.
+//
+// The response is encoded as XML if the client prefers `application/xml` or `text/xml` over
+// `application/json` and the response can be marshalled to XML (note that maps, including
+// Response, can not). Otherwise it falls back to Reply and responds with JSON.
+func ReplyNegotiated(w http.ResponseWriter, r *http.Request, response interface{}, status int) {
+	if prefersXML(r) {
+		if marshalledResponse, err := xml.Marshal(response); err == nil {
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(status)
+			w.Write([]byte(xml.Header))
+			w.Write(marshalledResponse)
+			return
+		}
+	}
+	Reply(w, response, status)
+}
+
+// prefersXML determines whether the client accepts XML with a higher quality than JSON.
+func prefersXML(r *http.Request) bool {
+	var jsonQ, xmlQ float64
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, q := parseAccepted(accepted)
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+			if q > jsonQ {
+				jsonQ = q
+			}
+		}
+		switch mediaType {
+		case "application/xml", "text/xml", "application/*", "*/*":
+			if q > xmlQ {
+				xmlQ = q
+			}
+		}
+	}
+	return xmlQ > jsonQ
+}
+
+// parseAccepted parses a single Accept header entry into a media type and its quality.
+func parseAccepted(accepted string) (string, float64) {
+	parts := strings.Split(accepted, ";")
+	q := 1.0
+	for _, param := range parts[1:] {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "q=") {
+			if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				q = value
+			}
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(parts[0])), q
+}
@@ -0,0 +1,55 @@
+package scroll
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+)
+
+type NegotiateSuite struct{}
+
+var _ = Suite(&NegotiateSuite{})
+
+type testResource struct {
+	XMLName struct{} `json:"-" xml:"resource"`
+	ID      string   `json:"id" xml:"id"`
+}
+
+func (s *NegotiateSuite) reply(accept string, response interface{}) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("GET", "/", nil)
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	recorder := httptest.NewRecorder()
+	ReplyNegotiated(recorder, request, response, http.StatusOK)
+	return recorder
+}
+
+func (s *NegotiateSuite) TestJSONByDefault(c *C) {
+	for _, accept := range []string{"", "*/*", "application/json", "application/xml;q=0.5, application/json"} {
+		recorder := s.reply(accept, testResource{ID: "1"})
+
+		c.Assert(recorder.Code, Equals, http.StatusOK)
+		c.Assert(recorder.Header().Get("Content-Type"), Equals, "application/json; charset=utf-8")
+		c.Assert(recorder.Body.String(), Equals, `{"id":"1"}`)
+	}
+}
+
+func (s *NegotiateSuite) TestXML(c *C) {
+	for _, accept := range []string{"application/xml", "text/xml", "application/json;q=0.5, application/xml"} {
+		recorder := s.reply(accept, testResource{ID: "1"})
+
+		c.Assert(recorder.Code, Equals, http.StatusOK)
+		c.Assert(recorder.Header().Get("Content-Type"), Equals, "application/xml; charset=utf-8")
+		c.Assert(recorder.Body.String(), Equals, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+`<resource><id>1</id></resource>`)
+	}
+}
+
+func (s *NegotiateSuite) TestXMLFallback(c *C) {
+	recorder := s.reply("application/xml", Response{"id": "1"})
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), Equals, "application/json; charset=utf-8")
+	c.Assert(recorder.Body.String(), Equals, `{"id":"1"}`)
+}
@@ -0,0 +1,129 @@
+package scroll
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upper bounds of the request latency histogram buckets, in seconds.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// promCollector accumulates request stats to expose them in the Prometheus text exposition format.
+type promCollector struct {
+	mutex    sync.Mutex
+	handlers map[string]*promHandlerStats
+}
+
+type promHandlerStats struct {
+	counts  map[int]uint64
+	buckets []uint64
+	sum     float64
+	count   uint64
+	bytes   uint64
+}
+
+func newPromCollector() *promCollector {
+	return &promCollector{handlers: make(map[string]*promHandlerStats)}
+}
+
+func (pc *promCollector) trackRequest(metricID string, status int, elapsedTime time.Duration) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	hs := pc.handlerStats(metricID)
+	hs.counts[status]++
+	seconds := elapsedTime.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			hs.buckets[i]++
+		}
+	}
+	hs.sum += seconds
+	hs.count++
+}
+
+func (pc *promCollector) trackResponseSize(metricID string, size int64) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	pc.handlerStats(metricID).bytes += uint64(size)
+}
+
+// handlerStats returns the stats of a handler, creating them on first use. Must be called with the mutex held.
+func (pc *promCollector) handlerStats(metricID string) *promHandlerStats {
+	hs, ok := pc.handlers[metricID]
+	if !ok {
+		hs = &promHandlerStats{counts: make(map[int]uint64), buckets: make([]uint64, len(latencyBuckets))}
+		pc.handlers[metricID] = hs
+	}
+	return hs
+}
+
+// writeTo writes the accumulated stats in the Prometheus text exposition format.
+func (pc *promCollector) writeTo(buf *bytes.Buffer) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+
+	metricIDs := make([]string, 0, len(pc.handlers))
+	for metricID := range pc.handlers {
+		metricIDs = append(metricIDs, metricID)
+	}
+	sort.Strings(metricIDs)
+
+	buf.WriteString("# HELP scroll_requests_total Total number of handled requests.\n")
+	buf.WriteString("# TYPE scroll_requests_total counter\n")
+	for _, metricID := range metricIDs {
+		hs := pc.handlers[metricID]
+		statuses := make([]int, 0, len(hs.counts))
+		for status := range hs.counts {
+			statuses = append(statuses, status)
+		}
+		sort.Ints(statuses)
+		for _, status := range statuses {
+			fmt.Fprintf(buf, "scroll_requests_total{handler=\"%s\",code=\"%d\"} %d\n",
+				escapeLabel(metricID), status, hs.counts[status])
+		}
+	}
+
+	buf.WriteString("# HELP scroll_request_duration_seconds Latency of handled requests.\n")
+	buf.WriteString("# TYPE scroll_request_duration_seconds histogram\n")
+	for _, metricID := range metricIDs {
+		hs := pc.handlers[metricID]
+		label := escapeLabel(metricID)
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(buf, "scroll_request_duration_seconds_bucket{handler=\"%s\",le=\"%s\"} %d\n",
+				label, strconv.FormatFloat(bound, 'g', -1, 64), hs.buckets[i])
+		}
+		fmt.Fprintf(buf, "scroll_request_duration_seconds_bucket{handler=\"%s\",le=\"+Inf\"} %d\n", label, hs.count)
+		fmt.Fprintf(buf, "scroll_request_duration_seconds_sum{handler=\"%s\"} %s\n",
+			label, strconv.FormatFloat(hs.sum, 'g', -1, 64))
+		fmt.Fprintf(buf, "scroll_request_duration_seconds_count{handler=\"%s\"} %d\n", label, hs.count)
+	}
+
+	buf.WriteString("# HELP scroll_response_bytes_total Total size of the response bodies before compression.\n")
+	buf.WriteString("# TYPE scroll_response_bytes_total counter\n")
+	for _, metricID := range metricIDs {
+		fmt.Fprintf(buf, "scroll_response_bytes_total{handler=\"%s\"} %d\n", escapeLabel(metricID), pc.handlers[metricID].bytes)
+	}
+}
+
+// handleMetrics exposes the accumulated request stats in the Prometheus text exposition format.
+func (pc *promCollector) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	pc.writeTo(&buf)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
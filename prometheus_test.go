@@ -0,0 +1,61 @@
+package scroll
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type PrometheusSuite struct{}
+
+var _ = Suite(&PrometheusSuite{})
+
+func (s *PrometheusSuite) TestMetricsEndpoint(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test", MetricsPath: "/metrics"})
+	c.Assert(err, IsNil)
+	app.stats.TrackRequest("messages", http.StatusOK, 3*time.Millisecond)
+	app.stats.TrackRequest("messages", http.StatusNotFound, 300*time.Millisecond)
+	app.stats.TrackRequest(`we"ird`, http.StatusOK, time.Millisecond)
+	app.stats.TrackResponseSize("messages", 120)
+	app.stats.TrackResponseSize("messages", 80)
+
+	recorder := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), Equals, "text/plain; version=0.0.4; charset=utf-8")
+	body := recorder.Body.String()
+	c.Assert(strings.Contains(body, "# TYPE scroll_requests_total counter\n"), Equals, true)
+	c.Assert(strings.Contains(body, `scroll_requests_total{handler="messages",code="200"} 1`+"\n"), Equals, true)
+	c.Assert(strings.Contains(body, `scroll_requests_total{handler="messages",code="404"} 1`+"\n"), Equals, true)
+	c.Assert(strings.Contains(body, `scroll_requests_total{handler="we\"ird",code="200"} 1`+"\n"), Equals, true)
+	c.Assert(strings.Contains(body, "# TYPE scroll_request_duration_seconds histogram\n"), Equals, true)
+	c.Assert(strings.Contains(body, `scroll_request_duration_seconds_bucket{handler="messages",le="0.005"} 1`+"\n"), Equals, true)
+	c.Assert(strings.Contains(body, `scroll_request_duration_seconds_bucket{handler="messages",le="0.5"} 2`+"\n"), Equals, true)
+	c.Assert(strings.Contains(body, `scroll_request_duration_seconds_bucket{handler="messages",le="+Inf"} 2`+"\n"), Equals, true)
+	c.Assert(strings.Contains(body, `scroll_request_duration_seconds_count{handler="messages"} 2`+"\n"), Equals, true)
+	c.Assert(strings.Contains(body, "# TYPE scroll_response_bytes_total counter\n"), Equals, true)
+	c.Assert(strings.Contains(body, `scroll_response_bytes_total{handler="messages"} 200`+"\n"), Equals, true)
+
+	// Every line is either a comment or a sample.
+	sample := regexp.MustCompile(`^[a-z_]+\{[^}]*\} [0-9.e+-]+$`)
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		if !strings.HasPrefix(line, "# ") {
+			c.Assert(sample.MatchString(line), Equals, true, Commentf("line: %s", line))
+		}
+	}
+}
+
+func (s *PrometheusSuite) TestMetricsEndpointDisabled(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+
+	recorder := httptest.NewRecorder()
+	app.GetHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	c.Assert(recorder.Code, Equals, http.StatusNotFound)
+}
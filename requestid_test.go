@@ -0,0 +1,71 @@
+package scroll
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type RequestIDSuite struct {
+	app *App
+}
+
+var _ = Suite(&RequestIDSuite{})
+
+func (s *RequestIDSuite) SetUpTest(c *C) {
+	app, err := NewAppWithConfig(AppConfig{Name: "test"})
+	c.Assert(err, IsNil)
+	err = app.AddHandler(Spec{
+		Methods: []string{"GET"},
+		Paths:   []string{"/id"},
+		HandlerWithContext: func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) (interface{}, error) {
+			return Response{"id": RequestIDFromContext(ctx)}, nil
+		},
+	})
+	c.Assert(err, IsNil)
+	s.app = app
+}
+
+func (s *RequestIDSuite) TestGeneratedWhenAbsent(c *C) {
+	recorder := httptest.NewRecorder()
+	s.app.GetHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/id", nil))
+
+	id := recorder.Header().Get("X-Request-Id")
+	c.Assert(id, Matches, "[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}")
+	c.Assert(decodeResponse(c, recorder)["id"], Equals, id)
+
+	other := httptest.NewRecorder()
+	s.app.GetHandler().ServeHTTP(other, httptest.NewRequest("GET", "/id", nil))
+	c.Assert(other.Header().Get("X-Request-Id"), Not(Equals), id)
+}
+
+func (s *RequestIDSuite) TestEchoedWhenPresent(c *C) {
+	request := httptest.NewRequest("GET", "/id", nil)
+	request.Header.Set("X-Request-Id", "abc-123")
+	recorder := httptest.NewRecorder()
+	s.app.GetHandler().ServeHTTP(recorder, request)
+
+	c.Assert(recorder.Header().Get("X-Request-Id"), Equals, "abc-123")
+	c.Assert(decodeResponse(c, recorder)["id"], Equals, "abc-123")
+}
+
+func (s *RequestIDSuite) TestLoggedWithRequest(c *C) {
+	var logged string
+	LogRequest = func(r *http.Request, status int, elapsedTime time.Duration, err error) {
+		logged = RequestIDFromContext(r.Context())
+	}
+	defer func() { LogRequest = logRequest }()
+
+	request := httptest.NewRequest("GET", "/id", nil)
+	request.Header.Set("X-Request-Id", "abc-123")
+	s.app.GetHandler().ServeHTTP(httptest.NewRecorder(), request)
+
+	c.Assert(logged, Equals, "abc-123")
+}
+
+func (s *RequestIDSuite) TestEmptyWithoutRequestID(c *C) {
+	c.Assert(RequestIDFromContext(context.Background()), Equals, "")
+}
@@ -0,0 +1,44 @@
+package scroll
+
+import (
+	"context"
+	"net/http"
+)
+
+type responseSizeKey struct{}
+
+// ResponseSizeFromContext returns the number of bytes of the response body written by the handler,
+// e.g. for request loggers to record it. Returns 0 if the context does not carry the size, which is
+// only set once the request has been replied to.
+//
+// The size is that of the body before compression (see Spec.Compress), since the request is logged
+// before the compressed body is written to the client.
+func ResponseSizeFromContext(ctx context.Context) int64 {
+	size, _ := ctx.Value(responseSizeKey{}).(int64)
+	return size
+}
+
+// withResponseSize returns a copy of the request whose context carries the size of its response.
+func withResponseSize(r *http.Request, size int64) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), responseSizeKey{}, size))
+}
+
+// countingResponseWriter counts the bytes of the response body written through it. It wraps the writer
+// passed to the handler, hence bodies compressed by compressHandler are counted uncompressed.
+type countingResponseWriter struct {
+	http.ResponseWriter
+	written int64
+}
+
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush flushes the underlying writer if it supports flushing, so that streamed responses are not held back.
+func (w *countingResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,31 @@
+package scroll
+
+import (
+	"net/url"
+
+	"github.com/gorilla/mux"
+)
+
+// RegisteredRoute is a handler registered with AddRoute.
+type RegisteredRoute struct {
+	// The spec the handler was registered with, its paths include the group prefix if any.
+	Spec Spec
+
+	routes []*mux.Route
+}
+
+// Route returns the router's route for the first path of the spec, e.g. to name it or to add matchers.
+// Matchers added this way are not reflected in the vulcand frontends of the handler.
+func (rr *RegisteredRoute) Route() *mux.Route {
+	return rr.routes[0]
+}
+
+// Routes returns the router's routes for the paths of the spec, in the same order as the paths.
+func (rr *RegisteredRoute) Routes() []*mux.Route {
+	return rr.routes
+}
+
+// URL builds a URL for the first path of the spec, the pairs are route variable names and values.
+func (rr *RegisteredRoute) URL(pairs ...string) (*url.URL, error) {
+	return rr.Route().URL(pairs...)
+}
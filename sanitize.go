@@ -2,7 +2,11 @@ package scroll
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // The AllowSet interface is implemented to detect if input is safe or not.
@@ -61,3 +65,100 @@ func (a AllowSetStrings) IsSafe(s string) error {
 	}
 	return nil
 }
+
+// AllowSetRegexp allows strings that fully match a regular expression.
+type AllowSetRegexp struct {
+	re *regexp.Regexp
+}
+
+// NewRegexpAllowSet compiles the pattern into an AllowSet. The pattern is anchored, i.e. a string is
+// only safe if the pattern matches it entirely.
+func NewRegexpAllowSet(pattern string) (AllowSet, error) {
+	re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	return AllowSetRegexp{re: re}, nil
+}
+
+func (a AllowSetRegexp) IsSafe(s string) error {
+	if !a.re.MatchString(s) {
+		return fmt.Errorf("string %v does not match %v", s, a.re)
+	}
+	return nil
+}
+
+// AllowSetIntRange allows strings that are integers within a range, bounds included.
+type AllowSetIntRange struct {
+	min, max int
+}
+
+func NewIntRangeAllowSet(min, max int) AllowSetIntRange {
+	return AllowSetIntRange{min: min, max: max}
+}
+
+func (a AllowSetIntRange) IsSafe(s string) error {
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("string %v is not an integer", s)
+	}
+	if value < a.min || value > a.max {
+		return fmt.Errorf("value %v out of range [%v, %v]", value, a.min, a.max)
+	}
+	return nil
+}
+
+// AllowSetLength allows strings whose length in runes is within a range, bounds included.
+type AllowSetLength struct {
+	min, max int
+}
+
+func NewLengthAllowSet(min, max int) AllowSetLength {
+	return AllowSetLength{min: min, max: max}
+}
+
+func (a AllowSetLength) IsSafe(s string) error {
+	length := utf8.RuneCountInString(s)
+	if length < a.min || length > a.max {
+		return fmt.Errorf("length %v out of range [%v, %v]", length, a.min, a.max)
+	}
+	return nil
+}
+
+type allowSetAnd []AllowSet
+
+// AndAllowSet combines AllowSets so that a string is safe only if it is safe for all of them. The
+// sets are checked in order and the error of the first set the string is not safe for is returned.
+func AndAllowSet(sets ...AllowSet) AllowSet {
+	return allowSetAnd(sets)
+}
+
+func (a allowSetAnd) IsSafe(s string) error {
+	for _, set := range a {
+		if err := set.IsSafe(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type allowSetOr []AllowSet
+
+// OrAllowSet combines AllowSets so that a string is safe if it is safe for any of them. The sets are
+// checked in order until one passes. If all of them fail, the returned error lists the errors of
+// all the sets.
+func OrAllowSet(sets ...AllowSet) AllowSet {
+	return allowSetOr(sets)
+}
+
+func (a allowSetOr) IsSafe(s string) error {
+	messages := make([]string, 0, len(a))
+	for _, set := range a {
+		err := set.IsSafe(s)
+		if err == nil {
+			return nil
+		}
+		messages = append(messages, err.Error())
+	}
+	return fmt.Errorf("none of the alternatives allowed: %v", strings.Join(messages, "; "))
+}
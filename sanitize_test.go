@@ -97,6 +97,233 @@ func TestAllowSetStrings(t *testing.T) {
 	}
 }
 
+func TestAllowSetRegexp(t *testing.T) {
+	tests := []struct {
+		inString        string
+		inPattern       string
+		outDidReturnErr bool
+	}{
+		// 0 - match
+		{
+			"my-slug-42",
+			`[a-z0-9-]+`,
+			false,
+		},
+		// 1 - no match
+		{
+			"My Slug",
+			`[a-z0-9-]+`,
+			true,
+		},
+		// 2 - partial match
+		{
+			"slug/42",
+			`[a-z0-9-]+`,
+			true,
+		},
+		// 3 - alternation is anchored as a whole
+		{
+			"foobar",
+			`foo|bar`,
+			true,
+		},
+	}
+
+	for i, tt := range tests {
+		allowSet, err := NewRegexpAllowSet(tt.inPattern)
+		if err != nil {
+			t.Fatalf("Test(%v), Got error: %v", i, err)
+		}
+		if g, w := parseError(allowSet.IsSafe(tt.inString)), tt.outDidReturnErr; g != w {
+			t.Errorf("Test(%v), Got IsSafe: %v, Want: %v", i, g, w)
+		}
+	}
+}
+
+func TestAllowSetRegexpInvalidPattern(t *testing.T) {
+	if _, err := NewRegexpAllowSet(`[a-z`); err == nil {
+		t.Errorf("Got no error for an invalid pattern")
+	}
+}
+
+func TestAllowSetIntRange(t *testing.T) {
+	tests := []struct {
+		inString        string
+		inAllow         AllowSet
+		outDidReturnErr bool
+	}{
+		// 0 - in range
+		{
+			"5",
+			NewIntRangeAllowSet(1, 10),
+			false,
+		},
+		// 1 - lower bound
+		{
+			"1",
+			NewIntRangeAllowSet(1, 10),
+			false,
+		},
+		// 2 - upper bound
+		{
+			"10",
+			NewIntRangeAllowSet(1, 10),
+			false,
+		},
+		// 3 - out of range
+		{
+			"11",
+			NewIntRangeAllowSet(1, 10),
+			true,
+		},
+		// 4 - negative
+		{
+			"-1",
+			NewIntRangeAllowSet(1, 10),
+			true,
+		},
+		// 5 - not numeric
+		{
+			"five",
+			NewIntRangeAllowSet(1, 10),
+			true,
+		},
+	}
+
+	for i, tt := range tests {
+		if g, w := parseError(tt.inAllow.IsSafe(tt.inString)), tt.outDidReturnErr; g != w {
+			t.Errorf("Test(%v), Got IsSafe: %v, Want: %v", i, g, w)
+		}
+	}
+}
+
+func TestAllowSetLength(t *testing.T) {
+	tests := []struct {
+		inString        string
+		inAllow         AllowSet
+		outDidReturnErr bool
+	}{
+		// 0 - in range
+		{
+			"abc",
+			NewLengthAllowSet(1, 3),
+			false,
+		},
+		// 1 - too short
+		{
+			"",
+			NewLengthAllowSet(1, 3),
+			true,
+		},
+		// 2 - too long
+		{
+			"abcd",
+			NewLengthAllowSet(1, 3),
+			true,
+		},
+		// 3 - runes are counted, not bytes
+		{
+			"üüü",
+			NewLengthAllowSet(1, 3),
+			false,
+		},
+	}
+
+	for i, tt := range tests {
+		if g, w := parseError(tt.inAllow.IsSafe(tt.inString)), tt.outDidReturnErr; g != w {
+			t.Errorf("Test(%v), Got IsSafe: %v, Want: %v", i, g, w)
+		}
+	}
+}
+
+func TestAndAllowSet(t *testing.T) {
+	alphanumeric := NewAllowSetBytes(`abcdefghijklmnopqrstuvwxyz0123456789`, 100)
+	tests := []struct {
+		inString        string
+		inAllow         AllowSet
+		outDidReturnErr bool
+	}{
+		// 0 - all pass
+		{
+			"abc123",
+			AndAllowSet(alphanumeric, NewLengthAllowSet(1, 32)),
+			false,
+		},
+		// 1 - fails on first
+		{
+			"abc-123",
+			AndAllowSet(alphanumeric, NewLengthAllowSet(1, 32)),
+			true,
+		},
+		// 2 - fails on second
+		{
+			"",
+			AndAllowSet(alphanumeric, NewLengthAllowSet(1, 32)),
+			true,
+		},
+		// 3 - no sets
+		{
+			"anything",
+			AndAllowSet(),
+			false,
+		},
+	}
+
+	for i, tt := range tests {
+		if g, w := parseError(tt.inAllow.IsSafe(tt.inString)), tt.outDidReturnErr; g != w {
+			t.Errorf("Test(%v), Got IsSafe: %v, Want: %v", i, g, w)
+		}
+	}
+
+	if err := AndAllowSet(alphanumeric, NewLengthAllowSet(1, 32)).IsSafe(""); err.Error() != "length 0 out of range [1, 32]" {
+		t.Errorf("Got error: %v", err)
+	}
+}
+
+func TestOrAllowSet(t *testing.T) {
+	tests := []struct {
+		inString        string
+		inAllow         AllowSet
+		outDidReturnErr bool
+	}{
+		// 0 - passes on first
+		{
+			"all",
+			OrAllowSet(NewAllowSetStrings([]string{"all"}), NewIntRangeAllowSet(1, 10)),
+			false,
+		},
+		// 1 - passes on second
+		{
+			"5",
+			OrAllowSet(NewAllowSetStrings([]string{"all"}), NewIntRangeAllowSet(1, 10)),
+			false,
+		},
+		// 2 - all fail
+		{
+			"none",
+			OrAllowSet(NewAllowSetStrings([]string{"all"}), NewIntRangeAllowSet(1, 10)),
+			true,
+		},
+		// 3 - no sets
+		{
+			"anything",
+			OrAllowSet(),
+			true,
+		},
+	}
+
+	for i, tt := range tests {
+		if g, w := parseError(tt.inAllow.IsSafe(tt.inString)), tt.outDidReturnErr; g != w {
+			t.Errorf("Test(%v), Got IsSafe: %v, Want: %v", i, g, w)
+		}
+	}
+
+	err := OrAllowSet(NewAllowSetStrings([]string{"all"}), NewIntRangeAllowSet(1, 10)).IsSafe("none")
+	if err.Error() != "none of the alternatives allowed: string none not allowed; string none is not an integer" {
+		t.Errorf("Got error: %v", err)
+	}
+}
+
 func parseError(err error) bool {
 	if err != nil {
 		return true
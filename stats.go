@@ -3,41 +3,111 @@ package scroll
 import (
 	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/mailgun/metrics"
 )
 
+// Metric name the requests that do not match any route are tracked under.
+const notFoundMetricName = "not_found"
+
+// Metric name the requests to registered paths with methods they are not registered for are tracked under.
+const methodNotAllowedMetricName = "method_not_allowed"
+
+// Non-standard status the requests canceled by clients are tracked with in the Prometheus metrics,
+// borrowed from nginx's "client closed request".
+const statusClientClosedRequest = 499
+
 type appStats struct {
-	c metrics.Client
+	c      metrics.Client
+	prom   *promCollector
+	prefix string
 }
 
-func newAppStats(client metrics.Client) *appStats {
+func newAppStats(client metrics.Client, prefix string) *appStats {
 	return &appStats{
-		c: client,
+		c:      client,
+		prefix: prefix,
 	}
 }
 
 func (s *appStats) TrackRequest(metricID string, status int, time time.Duration) {
+	if s.prom != nil {
+		s.prom.trackRequest(metricID, status, time)
+	}
 	if s.c == nil {
 		return
 	}
 
 	s.TrackRequestTime(metricID, time)
 	s.TrackTotalRequests(metricID)
+	s.TrackStatusClass(metricID, status)
 	if status != http.StatusOK {
 		s.TrackFailedRequests(metricID, status)
 	}
 }
 
 func (s *appStats) TrackRequestTime(metricID string, time time.Duration) {
-	s.c.TimingMs(fmt.Sprintf("api.%v.time", metricID), time, 1.0)
+	s.c.TimingMs(s.metricKey(metricID, "time"), time, 1.0)
 }
 
 func (s *appStats) TrackTotalRequests(metricID string) {
-	s.c.Inc(fmt.Sprintf("api.%v.count.total", metricID), 1, 1.0)
+	s.c.Inc(s.metricKey(metricID, "count.total"), 1, 1.0)
+}
+
+// TrackStatusClass counts a request by the class of its status, e.g. "count.4xx", which is handier
+// for alerting than counts by exact status.
+func (s *appStats) TrackStatusClass(metricID string, status int) {
+	s.c.Inc(s.metricKey(metricID, "count."+statusClass(status)), 1, 1.0)
 }
 
 func (s *appStats) TrackFailedRequests(metricID string, status int) {
-	s.c.Inc(fmt.Sprintf("api.%v.count.failed.%v", metricID, status), 1, 1.0)
+	s.c.Inc(s.metricKey(metricID, fmt.Sprintf("count.failed.%v", status)), 1, 1.0)
+}
+
+// statusClass returns the class of a status, e.g. "2xx" for 201 Created.
+func statusClass(status int) string {
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// metricKey returns the key a metric of a handler is emitted with, e.g. "myservice.api.messages.time".
+func (s *appStats) metricKey(metricID, metric string) string {
+	return fmt.Sprintf("%sapi.%s.%s", s.prefix, sanitizeMetricName(metricID), metric)
+}
+
+var unsafeMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// sanitizeMetricName replaces the characters metrics backends do not accept in metric names, e.g. the
+// slashes and braces of a route template, with underscores: "/users/{id}" becomes "users_id".
+func sanitizeMetricName(name string) string {
+	return strings.Trim(unsafeMetricChars.ReplaceAllString(name, "_"), "_")
+}
+
+// TrackResponseSize counts the bytes of the response bodies written by a handler before compression,
+// e.g. to analyze the size of payloads.
+func (s *appStats) TrackResponseSize(metricID string, size int64) {
+	if s.prom != nil {
+		s.prom.trackResponseSize(metricID, size)
+	}
+	if s.c == nil {
+		return
+	}
+	s.c.Inc(s.metricKey(metricID, "bytes"), size, 1.0)
+}
+
+// TrackCanceledRequest tracks a request canceled by the client, e.g. because it disconnected. Such
+// requests are counted apart from failed requests, so that client disconnects do not look like errors.
+func (s *appStats) TrackCanceledRequest(metricID string, time time.Duration) {
+	if s.prom != nil {
+		s.prom.trackRequest(metricID, statusClientClosedRequest, time)
+	}
+	if s.c == nil {
+		return
+	}
+
+	s.TrackRequestTime(metricID, time)
+	s.TrackTotalRequests(metricID)
+	s.c.Inc(s.metricKey(metricID, "count.canceled"), 1, 1.0)
 }
@@ -0,0 +1,45 @@
+package scroll
+
+import (
+	"net/http"
+
+	. "gopkg.in/check.v1"
+)
+
+type StatsSuite struct{}
+
+var _ = Suite(&StatsSuite{})
+
+func (s *StatsSuite) TestMetricKey(c *C) {
+	for i, tc := range []struct {
+		prefix   string
+		metricID string
+		expected string
+	}{
+		{"", "messages", "api.messages.time"},
+		{"myservice.", "messages", "myservice.api.messages.time"},
+		{"myservice.", "messages.send", "myservice.api.messages.send.time"},
+		{"myservice.", "/users/{id}", "myservice.api.users_id.time"},
+		{"myservice.", "get users:list", "myservice.api.get_users_list.time"},
+	} {
+		c.Logf("Test case #%d", i)
+		stats := newAppStats(nil, tc.prefix)
+		c.Assert(stats.metricKey(tc.metricID, "time"), Equals, tc.expected)
+	}
+}
+
+func (s *StatsSuite) TestStatusClassCounters(c *C) {
+	stats := newAppStats(nil, "myservice.")
+	for i, tc := range []struct {
+		status   int
+		expected string
+	}{
+		{http.StatusOK, "myservice.api.messages.count.2xx"},
+		{http.StatusCreated, "myservice.api.messages.count.2xx"},
+		{http.StatusNotFound, "myservice.api.messages.count.4xx"},
+		{http.StatusInternalServerError, "myservice.api.messages.count.5xx"},
+	} {
+		c.Logf("Test case #%d", i)
+		c.Assert(stats.metricKey("messages", "count."+statusClass(tc.status)), Equals, tc.expected)
+	}
+}
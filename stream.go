@@ -0,0 +1,40 @@
+package scroll
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+)
+
+// StreamJSON writes the items received from the channel as newline delimited JSON, one object per
+// line, until the channel is closed.
+//
+// If the writer implements http.Flusher every object is flushed to the client as soon as it is
+// written, otherwise the output is buffered. Since the status is sent before the first object, an
+// error returned by StreamJSON can no longer be reported to the client with a different status.
+// It is meant to be used from a Spec.RawHandler since the other handler kinds reply on their own.
+func StreamJSON(w http.ResponseWriter, ch <-chan interface{}) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		buf := bufio.NewWriter(w)
+		encoder := json.NewEncoder(buf)
+		for item := range ch {
+			if err := encoder.Encode(item); err != nil {
+				return err
+			}
+		}
+		return buf.Flush()
+	}
+
+	encoder := json.NewEncoder(w)
+	for item := range ch {
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+		flusher.Flush()
+	}
+	return nil
+}
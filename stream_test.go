@@ -0,0 +1,76 @@
+package scroll
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+)
+
+type StreamSuite struct{}
+
+var _ = Suite(&StreamSuite{})
+
+// plainWriter hides the http.Flusher implementation of the underlying recorder.
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+func produce(n int) <-chan interface{} {
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+		for i := 0; i < n; i++ {
+			ch <- Response{"id": i}
+		}
+	}()
+	return ch
+}
+
+func countObjects(c *C, recorder *httptest.ResponseRecorder) int {
+	count := 0
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		var response Response
+		c.Assert(json.Unmarshal(scanner.Bytes(), &response), IsNil)
+		c.Assert(response["id"], Equals, float64(count))
+		count++
+	}
+	c.Assert(scanner.Err(), IsNil)
+	return count
+}
+
+func (s *StreamSuite) TestStreamJSON(c *C) {
+	recorder := httptest.NewRecorder()
+
+	err := StreamJSON(recorder, produce(5))
+
+	c.Assert(err, IsNil)
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), Equals, "application/x-ndjson")
+	c.Assert(recorder.Flushed, Equals, true)
+	c.Assert(countObjects(c, recorder), Equals, 5)
+}
+
+func (s *StreamSuite) TestStreamJSONWithoutFlusher(c *C) {
+	recorder := httptest.NewRecorder()
+
+	err := StreamJSON(plainWriter{recorder}, produce(5))
+
+	c.Assert(err, IsNil)
+	c.Assert(recorder.Flushed, Equals, false)
+	c.Assert(countObjects(c, recorder), Equals, 5)
+}
+
+func (s *StreamSuite) TestStreamJSONMarshalFailure(c *C) {
+	recorder := httptest.NewRecorder()
+	ch := make(chan interface{}, 1)
+	ch <- Response{"bad": make(chan int)}
+	close(ch)
+
+	err := StreamJSON(recorder, ch)
+
+	c.Assert(err, NotNil)
+}
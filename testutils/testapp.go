@@ -1,6 +1,7 @@
 package testutils
 
 import (
+	"net/http"
 	"net/http/httptest"
 
 	"github.com/gorilla/mux"
@@ -26,10 +27,15 @@ func NewTestApp() *TestApp {
 	if err != nil {
 		panic(err)
 	}
+	// Resolve the app's handler on every request, so middlewares added to the app
+	// after the test server is started are honored.
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.GetHandler().ServeHTTP(w, r)
+	})
 	return &TestApp{
 		RestHelper{},
 		app,
-		httptest.NewServer(router),
+		httptest.NewServer(handler),
 	}
 }
 
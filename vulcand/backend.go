@@ -1,9 +1,12 @@
 package vulcand
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/mailgun/iptools"
 )
@@ -12,6 +15,63 @@ type backendSpec struct {
 	AppName string
 	ID      string
 	URL     string
+	TLS     *TLSSettings
+	HTTP    *HTTPSettings
+	Weight  int
+}
+
+// TLSSettings are settings vulcand uses to connect to backend servers over HTTPS.
+type TLSSettings struct {
+	InsecureSkipVerify     bool   `json:"InsecureSkipVerify,omitempty"`
+	SessionTicketsDisabled bool   `json:"SessionTicketsDisabled,omitempty"`
+	MinVersion             string `json:"MinVersion,omitempty"`
+	MaxVersion             string `json:"MaxVersion,omitempty"`
+}
+
+// HTTPSettings are settings of the connections vulcand makes to backend servers. Settings that are not
+// specified are left to vulcand's defaults.
+type HTTPSettings struct {
+	// How long vulcand waits for a response from a server, e.g. longer for slow endpoints.
+	ReadTimeout time.Duration
+
+	// How long vulcand waits to establish a connection to a server.
+	DialTimeout time.Duration
+
+	// How long vulcand waits for the TLS handshake with a server to complete.
+	TLSHandshakeTimeout time.Duration
+
+	// Period of the keep-alive probes of idle connections to the servers.
+	KeepAlivePeriod time.Duration
+
+	// Maximum number of idle connections vulcand keeps to a server.
+	MaxIdleConnsPerHost int
+}
+
+func (hs *HTTPSettings) validate() error {
+	if hs.ReadTimeout < 0 || hs.DialTimeout < 0 || hs.TLSHandshakeTimeout < 0 || hs.KeepAlivePeriod < 0 {
+		return errors.New("invalid backend HTTP settings: durations must not be negative")
+	}
+	if hs.MaxIdleConnsPerHost < 0 {
+		return errors.New("invalid backend HTTP settings: max idle connections must not be negative")
+	}
+	return nil
+}
+
+type backendSettings struct {
+	Timeouts  *backendTimeouts  `json:"Timeouts,omitempty"`
+	KeepAlive *backendKeepAlive `json:"KeepAlive,omitempty"`
+	TLS       *TLSSettings      `json:"TLS,omitempty"`
+}
+
+type backendTimeouts struct {
+	Read         string `json:"Read,omitempty"`
+	Dial         string `json:"Dial,omitempty"`
+	TLSHandshake string `json:"TLSHandshake,omitempty"`
+}
+
+type backendKeepAlive struct {
+	Period              string `json:"Period,omitempty"`
+	MaxIdleConnsPerHost int    `json:"MaxIdleConnsPerHost,omitempty"`
 }
 
 func newBackendSpec(appName, ip string, port int) (*backendSpec, error) {
@@ -34,11 +94,49 @@ func newBackendSpecWithID(id string, appName string, ip string, port int) (*back
 	}, nil
 }
 
+// enableTLS makes vulcand connect to the backend server over HTTPS with the provided settings.
+func (bes *backendSpec) enableTLS(settings *TLSSettings) {
+	bes.URL = "https" + strings.TrimPrefix(bes.URL, "http")
+	bes.TLS = settings
+}
+
 func (bes *backendSpec) typeSpec() string {
-	return `{"Type":"http"}`
+	settings := backendSettings{TLS: bes.TLS}
+	if hs := bes.HTTP; hs != nil {
+		if hs.ReadTimeout > 0 || hs.DialTimeout > 0 || hs.TLSHandshakeTimeout > 0 {
+			settings.Timeouts = &backendTimeouts{
+				Read:         formatDuration(hs.ReadTimeout),
+				Dial:         formatDuration(hs.DialTimeout),
+				TLSHandshake: formatDuration(hs.TLSHandshakeTimeout),
+			}
+		}
+		if hs.KeepAlivePeriod > 0 || hs.MaxIdleConnsPerHost > 0 {
+			settings.KeepAlive = &backendKeepAlive{
+				Period:              formatDuration(hs.KeepAlivePeriod),
+				MaxIdleConnsPerHost: hs.MaxIdleConnsPerHost,
+			}
+		}
+	}
+	if settings == (backendSettings{}) {
+		return `{"Type":"http"}`
+	}
+	settingsJSON, _ := json.Marshal(settings)
+	return fmt.Sprintf(`{"Type":"http","Settings":%s}`, settingsJSON)
+}
+
+// formatDuration formats a duration the way vulcand parses it, or returns an empty string if it is
+// not specified.
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return d.String()
 }
 
 func (bes *backendSpec) serverSpec() string {
+	if bes.Weight > 0 {
+		return fmt.Sprintf(`{"URL":"%s","Weight":%d}`, bes.URL, bes.Weight)
+	}
 	return fmt.Sprintf(`{"URL":"%s"}`, bes.URL)
 }
 
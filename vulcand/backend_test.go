@@ -0,0 +1,85 @@
+package vulcand
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type BackendSuite struct{}
+
+var _ = Suite(&BackendSuite{})
+
+func (s *BackendSuite) TestPlainHTTP(c *C) {
+	r, err := NewRegistry(Config{}, "ghost", "192.168.19.2", 8000)
+	c.Assert(err, IsNil)
+
+	c.Assert(r.backendSpec.typeSpec(), Equals, `{"Type":"http"}`)
+	c.Assert(r.backendSpec.serverSpec(), Equals, `{"URL":"http://192.168.19.2:8000"}`)
+}
+
+func (s *BackendSuite) TestTLS(c *C) {
+	cfg := Config{BackendTLS: &TLSSettings{InsecureSkipVerify: true, MinVersion: "VersionTLS12"}}
+
+	// When
+	r, err := NewRegistry(cfg, "ghost", "192.168.19.2", 8000)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(r.backendSpec.typeSpec(), Equals, `{"Type":"http","Settings":{"TLS":{"InsecureSkipVerify":true,"MinVersion":"VersionTLS12"}}}`)
+	c.Assert(r.backendSpec.serverSpec(), Equals, `{"URL":"https://192.168.19.2:8000"}`)
+}
+
+func (s *BackendSuite) TestWeight(c *C) {
+	// When
+	r, err := NewRegistry(Config{Weight: 10}, "ghost", "192.168.19.2", 8000)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(r.backendSpec.serverSpec(), Equals, `{"URL":"http://192.168.19.2:8000","Weight":10}`)
+}
+
+func (s *BackendSuite) TestNegativeWeight(c *C) {
+	_, err := NewRegistry(Config{Weight: -1}, "ghost", "192.168.19.2", 8000)
+	c.Assert(err, ErrorMatches, "invalid weight -1: must not be negative")
+}
+
+func (s *BackendSuite) TestServerID(c *C) {
+	r1, err := NewRegistry(Config{ServerID: "pod-1"}, "ghost", "192.168.19.2", 8000)
+	c.Assert(err, IsNil)
+	r2, err := NewRegistry(Config{ServerID: "pod-2"}, "ghost", "192.168.19.2", 8000)
+	c.Assert(err, IsNil)
+
+	c.Assert(r1.backendSpec.ID, Equals, "pod-1")
+	c.Assert(r2.backendSpec.ID, Equals, "pod-2")
+	c.Assert(r1.backendSpec.serverSpec(), Equals, `{"URL":"http://192.168.19.2:8000"}`)
+
+	_, err = NewRegistry(Config{ServerID: "pods/1"}, "ghost", "192.168.19.2", 8000)
+	c.Assert(err, ErrorMatches, `invalid server ID "pods/1": must not contain slashes`)
+}
+
+func (s *BackendSuite) TestHTTPSettings(c *C) {
+	cfg := Config{
+		BackendHTTP: &HTTPSettings{ReadTimeout: time.Minute, DialTimeout: 5 * time.Second, MaxIdleConnsPerHost: 12},
+		BackendTLS:  &TLSSettings{InsecureSkipVerify: true},
+	}
+
+	// When
+	r, err := NewRegistry(cfg, "ghost", "192.168.19.2", 8000)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(r.backendSpec.typeSpec(), Equals, `{"Type":"http","Settings":{"Timeouts":{"Read":"1m0s","Dial":"5s"},"KeepAlive":{"MaxIdleConnsPerHost":12},"TLS":{"InsecureSkipVerify":true}}}`)
+}
+
+func (s *BackendSuite) TestEmptyHTTPSettings(c *C) {
+	r, err := NewRegistry(Config{BackendHTTP: &HTTPSettings{}}, "ghost", "192.168.19.2", 8000)
+	c.Assert(err, IsNil)
+
+	c.Assert(r.backendSpec.typeSpec(), Equals, `{"Type":"http"}`)
+}
+
+func (s *BackendSuite) TestNegativeHTTPSettings(c *C) {
+	_, err := NewRegistry(Config{BackendHTTP: &HTTPSettings{ReadTimeout: -time.Second}}, "ghost", "192.168.19.2", 8000)
+	c.Assert(err, ErrorMatches, "invalid backend HTTP settings: durations must not be negative")
+}
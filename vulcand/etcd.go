@@ -0,0 +1,85 @@
+package vulcand
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"time"
+
+	etcd "github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+)
+
+// EtcdConfig describes a connection to an etcd cluster that may require authentication and TLS,
+// use NewEtcdConfig to turn it into a Config.Etcd value.
+type EtcdConfig struct {
+	Endpoints []string
+
+	// Basic authentication credentials. Either both or none must be provided.
+	Username string
+	Password string
+
+	// Client certificate and key files used to authenticate with etcd over TLS. Either both or
+	// none must be provided.
+	CertFile string
+	KeyFile  string
+
+	// CA certificate file used to verify etcd servers. If provided without a client certificate,
+	// TLS is used without client authentication. If not provided, the host's root CAs are used.
+	CAFile string
+
+	DialTimeout time.Duration
+}
+
+// NewEtcdConfig validates the provided connection settings and builds an etcd client config out of
+// them, loading the certificate files if any.
+func NewEtcdConfig(cfg EtcdConfig) (*etcd.Config, error) {
+	etcdCfg := &etcd.Config{
+		Endpoints:   cfg.Endpoints,
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+		DialTimeout: cfg.DialTimeout,
+	}
+	if err := validateEtcdConfig(etcdCfg); err != nil {
+		return nil, err
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("invalid etcd config: both certificate and key files are required")
+	}
+	if cfg.CertFile == "" && cfg.CAFile == "" {
+		return etcdCfg, nil
+	}
+
+	etcdCfg.TLS = &tls.Config{}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load etcd client certificate, cert=%s, key=%s",
+				cfg.CertFile, cfg.KeyFile)
+		}
+		etcdCfg.TLS.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read etcd CA file, ca=%s", cfg.CAFile)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in etcd CA file, ca=%s", cfg.CAFile)
+		}
+		etcdCfg.TLS.RootCAs = pool
+	}
+	return etcdCfg, nil
+}
+
+// validateEtcdConfig catches misconfigurations that would otherwise fail cryptically when connecting.
+func validateEtcdConfig(cfg *etcd.Config) error {
+	if len(cfg.Endpoints) == 0 {
+		return errors.New("invalid etcd config: at least one endpoint is required")
+	}
+	if (cfg.Username == "") != (cfg.Password == "") {
+		return errors.New("invalid etcd config: both username and password are required")
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package vulcand
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type EtcdSuite struct{}
+
+var _ = Suite(&EtcdSuite{})
+
+// writeCert writes a self-signed certificate and its key to the provided directory.
+func writeCert(c *C, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "etcd"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	c.Assert(err, IsNil)
+	return certFile, keyFile
+}
+
+func (s *EtcdSuite) TestTLS(c *C) {
+	certFile, keyFile := writeCert(c, c.MkDir())
+
+	// When
+	cfg, err := NewEtcdConfig(EtcdConfig{
+		Endpoints:   []string{"https://localhost:2379"},
+		Username:    "root",
+		Password:    "rootpw",
+		CertFile:    certFile,
+		KeyFile:     keyFile,
+		CAFile:      certFile,
+		DialTimeout: time.Second,
+	})
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Endpoints, DeepEquals, []string{"https://localhost:2379"})
+	c.Assert(cfg.Username, Equals, "root")
+	c.Assert(cfg.Password, Equals, "rootpw")
+	c.Assert(cfg.DialTimeout, Equals, time.Second)
+	c.Assert(cfg.TLS, NotNil)
+	c.Assert(cfg.TLS.Certificates, HasLen, 1)
+	c.Assert(cfg.TLS.RootCAs, NotNil)
+}
+
+func (s *EtcdSuite) TestPlain(c *C) {
+	cfg, err := NewEtcdConfig(EtcdConfig{Endpoints: []string{"http://localhost:2379"}})
+	c.Assert(err, IsNil)
+	c.Assert(cfg.TLS, IsNil)
+}
+
+func (s *EtcdSuite) TestInvalid(c *C) {
+	dir := c.MkDir()
+	certFile, keyFile := writeCert(c, dir)
+	for i, tc := range []struct {
+		cfg EtcdConfig
+		err string
+	}{{
+		cfg: EtcdConfig{},
+		err: "invalid etcd config: at least one endpoint is required",
+	}, {
+		cfg: EtcdConfig{Endpoints: []string{"https://localhost:2379"}, Username: "root"},
+		err: "invalid etcd config: both username and password are required",
+	}, {
+		cfg: EtcdConfig{Endpoints: []string{"https://localhost:2379"}, CertFile: certFile},
+		err: "invalid etcd config: both certificate and key files are required",
+	}, {
+		cfg: EtcdConfig{Endpoints: []string{"https://localhost:2379"}, CertFile: certFile, KeyFile: filepath.Join(dir, "missing.pem")},
+		err: "failed to load etcd client certificate, .*",
+	}, {
+		cfg: EtcdConfig{Endpoints: []string{"https://localhost:2379"}, CAFile: keyFile},
+		err: "no certificates found in etcd CA file, .*",
+	}} {
+		c.Logf("Test case #%d", i)
+		_, err := NewEtcdConfig(tc.cfg)
+		c.Assert(err, ErrorMatches, tc.err)
+	}
+}
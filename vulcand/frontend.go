@@ -1,10 +1,10 @@
 package vulcand
 
 import (
+	"bytes"
 	"crypto/sha1"
 	"encoding/json"
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
 
@@ -33,7 +33,15 @@ type frontendOptions struct {
 }
 
 func (fo frontendOptions) spec() string {
-	return fmt.Sprintf(`{"FailoverPredicate":"%s","PassHostHeader":%t}`, fo.FailoverPredicate, fo.PassHostHeader)
+	return fmt.Sprintf(`{"FailoverPredicate":%s,"PassHostHeader":%t}`, strconv.Quote(fo.FailoverPredicate), fo.PassHostHeader)
+}
+
+// FrontendOptions are optional settings of a frontend registered with AddFrontendWithOptions.
+type FrontendOptions struct {
+	// Expression that determines whether vulcand should retry a failed request on another server,
+	// e.g. "IsNetworkError() && Attempts() <= 2". Use "false" to disable retries. If not specified,
+	// the default "(IsNetworkError() || ResponseCode() == 503) && Attempts() <= 2" is used.
+	FailoverPredicate string
 }
 
 func newFrontendSpec(appName, host, path string, methods []string, middlewares []Middleware) *frontendSpec {
@@ -71,21 +79,31 @@ func (fes *frontendSpec) hash() (string, error) {
 	return fmt.Sprintf("%x", d.Sum(nil)), nil
 }
 
+// route returns the frontend's route expression, it matches any method if no methods are listed.
 func (fes *frontendSpec) route() string {
 	var methodExpr string
-	if len(fes.Methods) == 1 {
+	switch len(fes.Methods) {
+	case 0:
+		return fmt.Sprintf(`Host("%s") && Path("%s")`, fes.Host, fes.URLPath)
+	case 1:
 		methodExpr = fmt.Sprintf(`Method("%s")`, fes.Methods[0])
-	} else {
+	default:
 		methodExpr = fmt.Sprintf(`MethodRegexp("%s")`, strings.Join(fes.Methods, "|"))
 	}
 	return fmt.Sprintf(`Host("%s") && %s && Path("%s")`, fes.Host, methodExpr, fes.URLPath)
 }
 
 func makeLocationID(methods []string, path string) string {
+	if len(methods) == 0 {
+		methods = []string{"any"}
+	}
 	return strings.ToLower(strings.Replace(fmt.Sprintf("%v%v", strings.Join(methods, "."), path), "/", ".", -1))
 }
 
 func makeLocationPath(methods []string, path string) string {
+	if len(methods) == 0 {
+		return fmt.Sprintf(`TrieRoute("%v")`, path)
+	}
 	return fmt.Sprintf(`TrieRoute("%v", "%v")`, strings.Join(methods, `", "`), path)
 }
 
@@ -93,8 +111,33 @@ func makeLocationPath(methods []string, path string) string {
 //
 // It does two things:
 //  - Strips regular expression parts of path variables, i.e. turns "/v2/{id:[0-9]+}" into "/v2/{id}".
+//    Patterns may contain braces themselves, e.g. "/v2/{id:[0-9]{3}}".
 //  - Replaces curly brackets with angle brackets, i.e. turns "/v2/{id}" into "/v2/<id>".
 func normalizePath(path string) string {
-	path = regexp.MustCompile("(:[^}]+)").ReplaceAllString(path, "")
-	return strings.Replace(strings.Replace(path, "{", "<", -1), "}", ">", -1)
+	var b bytes.Buffer
+	depth := 0
+	inPattern := false
+	for _, r := range path {
+		switch {
+		case r == '{':
+			depth++
+			if depth == 1 {
+				b.WriteRune('<')
+				continue
+			}
+		case r == '}':
+			depth--
+			if depth == 0 {
+				inPattern = false
+				b.WriteRune('>')
+				continue
+			}
+		case r == ':' && depth == 1:
+			inPattern = true
+		}
+		if !inPattern {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
 }
@@ -1,9 +1,15 @@
 package vulcand
 
 import (
+	"testing"
+
 	. "gopkg.in/check.v1"
 )
 
+func TestFrontend(t *testing.T) {
+	TestingT(t)
+}
+
 type FrontendSuite struct{}
 
 var _ = Suite(&FrontendSuite{})
@@ -46,6 +52,10 @@ func (s *FrontendSuite) TestSpecAndHash(c *C) {
 			[]Middleware{{Type: "T1", ID: "Id1", Priority: 7}}),
 		spec: `{"Type":"http","BackendId":"ghost","Route":"Host(\"example.com\") && Method(\"GET\") && Path(\"/v2/<domain>/events\")","Settings":{"FailoverPredicate":"(IsNetworkError() || ResponseCode() == 503) && Attempts() <= 2","PassHostHeader":true}}`,
 		hash: "71baccfabc77c0bdaf63df4d9c8aa2408bf6f3f6",
+	}, {
+		fes:  newFrontendSpec("ghost", "example.com", "/v2/<domain>/events", nil, nil),
+		spec: `{"Type":"http","BackendId":"ghost","Route":"Host(\"example.com\") && Path(\"/v2/<domain>/events\")","Settings":{"FailoverPredicate":"(IsNetworkError() || ResponseCode() == 503) && Attempts() <= 2","PassHostHeader":true}}`,
+		hash: "4f76f90174aad77e03588cb0e6b157c4d1e3c9b3",
 	}} {
 		c.Logf("Test case #%d", i)
 
@@ -59,3 +69,100 @@ func (s *FrontendSuite) TestSpecAndHash(c *C) {
 		c.Assert(hash, Equals, tc.hash)
 	}
 }
+
+func (s *FrontendSuite) TestNormalizePath(c *C) {
+	for i, tc := range []struct {
+		path       string
+		normalized string
+	}{
+		{"/v2/events", "/v2/events"},
+		{"/v2/{domain}/events", "/v2/<domain>/events"},
+		{"/v2/{domain}/events/{id:[0-9]+}", "/v2/<domain>/events/<id>"},
+		{"/v2/events/{id:[0-9]{3}}/{name:[a-z]{1,8}}", "/v2/events/<id>/<name>"},
+		{"/v2/events/{id:[0-9a-f:]+}", "/v2/events/<id>"},
+	} {
+		c.Logf("Test case #%d", i)
+		c.Assert(normalizePath(tc.path), Equals, tc.normalized)
+	}
+
+	fes := newFrontendSpec("ghost", "example.com", "/v2/events/{id:[0-9]{3}}", []string{"GET"}, nil)
+	c.Assert(fes.route(), Equals, `Host("example.com") && Method("GET") && Path("/v2/events/<id>")`)
+}
+
+func (s *FrontendSuite) TestCustomFailoverPredicate(c *C) {
+	r, err := NewRegistry(Config{}, "ghost", "192.168.19.2", 8000)
+	c.Assert(err, IsNil)
+
+	// When
+	err = r.AddFrontendWithOptions("example.com", "/v2/<domain>/events", []string{"POST"}, nil,
+		FrontendOptions{FailoverPredicate: `IsNetworkError() || ResponseCode() == 502`})
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(r.frontendSpecs[0].spec(), Equals, `{"Type":"http","BackendId":"ghost","Route":"Host(\"example.com\") && Method(\"POST\") && Path(\"/v2/<domain>/events\")","Settings":{"FailoverPredicate":"IsNetworkError() || ResponseCode() == 502","PassHostHeader":true}}`)
+}
+
+func (s *FrontendSuite) TestDefaultFailoverPredicate(c *C) {
+	r, err := NewRegistry(Config{}, "ghost", "192.168.19.2", 8000)
+	c.Assert(err, IsNil)
+
+	// When
+	err = r.AddFrontendWithOptions("example.com", "/v2/<domain>/events", []string{"GET"}, nil, FrontendOptions{})
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(r.frontendSpecs[0].Options.FailoverPredicate, Equals, defaultFailoverPredicate)
+}
+
+func (s *FrontendSuite) TestBlankFailoverPredicate(c *C) {
+	r, err := NewRegistry(Config{}, "ghost", "192.168.19.2", 8000)
+	c.Assert(err, IsNil)
+
+	// When
+	err = r.AddFrontendWithOptions("example.com", "/v2/<domain>/events", []string{"GET"}, nil,
+		FrontendOptions{FailoverPredicate: "  "})
+
+	// Then
+	c.Assert(err, ErrorMatches, "failover predicate must not be blank")
+	c.Assert(r.frontendSpecs, HasLen, 0)
+}
+
+func (s *FrontendSuite) TestRemoveFrontend(c *C) {
+	r, err := NewRegistry(Config{}, "ghost", "192.168.19.2", 8000)
+	c.Assert(err, IsNil)
+	r.AddFrontend("example.com", "/v2/{domain}/events", []string{"GET"}, nil)
+	r.AddFrontend("example.com", "/v2/{domain}/events", []string{"POST"}, nil)
+
+	// When
+	err = r.RemoveFrontend("EXAMPLE.com", "/v2/{domain}/events", []string{"get"})
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(r.frontendSpecs, HasLen, 1)
+	c.Assert(r.frontendSpecs[0].Methods, DeepEquals, []string{"POST"})
+
+	err = r.RemoveFrontend("example.com", "/v2/{domain}/events", []string{"GET"})
+	c.Assert(err, ErrorMatches, "frontend not found: host=example.com, id=get.v2.<domain>.events")
+}
+
+func (s *FrontendSuite) TestInvalidPath(c *C) {
+	r, err := NewRegistry(Config{}, "ghost", "192.168.19.2", 8000)
+	c.Assert(err, IsNil)
+
+	for i, tc := range []struct {
+		path string
+		err  string
+	}{
+		{"", "invalid frontend path: must not be empty"},
+		{"v2/events", `invalid frontend path "v2/events": must start with a slash`},
+	} {
+		c.Logf("Test case #%d", i)
+
+		// When
+		err = r.AddFrontend("example.com", tc.path, []string{"GET"}, nil)
+
+		// Then
+		c.Assert(err, ErrorMatches, tc.err)
+		c.Assert(r.frontendSpecs, HasLen, 0)
+	}
+}
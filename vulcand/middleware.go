@@ -2,8 +2,19 @@ package vulcand
 
 import "fmt"
 
+// DefaultMiddlewarePriority is the priority set by the vulcand/middleware helpers. It is not considered an
+// explicit priority, see Middleware.
 const DefaultMiddlewarePriority = 1
 
+// Middleware is a vulcand middleware registered with a frontend. It is the one middleware type used
+// throughout scroll, e.g. by scroll.Spec, and the helpers of the vulcand/middleware package build it,
+// e.g. middleware.NewRateLimit.
+//
+// Vulcand executes middlewares with lower priorities first. If no middleware of a frontend has an explicit
+// priority, i.e. all priorities are either zero or DefaultMiddlewarePriority as set by the vulcand/middleware
+// helpers, priorities are assigned according to the positions of the middlewares in the list instead. The
+// execution order of middlewares with equal explicit priorities is up to vulcand, so give them distinct
+// priorities if their order matters.
 type Middleware struct {
 	Type     string         `json:"Type"`
 	ID       string         `json:"Id"`
@@ -13,7 +24,32 @@ type Middleware struct {
 
 type MiddlewareSpec interface{}
 
+// validator is implemented by middleware specs that can check themselves before registration.
+type validator interface {
+	Validate() error
+}
+
 func (m Middleware) String() string {
 	return fmt.Sprintf("Middleware(Type=%v, ID=%v, Priority=%v, Spec=%v)",
 		m.Type, m.ID, m.Priority, m.Spec)
 }
+
+// withPriorities returns a copy of the middlewares with priorities assigned by list position, unless
+// any of the middlewares has an explicit priority.
+func withPriorities(middlewares []Middleware) []Middleware {
+	explicit := false
+	for _, mw := range middlewares {
+		if mw.Priority != 0 && mw.Priority != DefaultMiddlewarePriority {
+			explicit = true
+			break
+		}
+	}
+	result := make([]Middleware, len(middlewares))
+	for i, mw := range middlewares {
+		if !explicit {
+			mw.Priority = i
+		}
+		result[i] = mw
+	}
+	return result
+}
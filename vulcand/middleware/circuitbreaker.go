@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -33,6 +34,20 @@ func NewCircuitBreaker(spec CircuitBreaker) vulcand.Middleware {
 	}
 }
 
+// Validate returns an error if the spec would be rejected by vulcand.
+func (cb CircuitBreaker) Validate() error {
+	if cb.Condition == "" {
+		return errors.New("circuit breaker condition is required")
+	}
+	if cb.Fallback == "" {
+		return errors.New("circuit breaker fallback is required")
+	}
+	if cb.CheckPeriod < 0 || cb.FallbackDuration < 0 || cb.RecoveryDuration < 0 {
+		return errors.New("circuit breaker durations must not be negative")
+	}
+	return nil
+}
+
 func (cb CircuitBreaker) String() string {
 	return fmt.Sprintf("CircuitBreaker(Condition=%v, Fallback=%v, CheckPeriod=%v, FallbackDuration=%v, RecoveryDuration=%v, OnTripped=%v, OnStandby=%v)",
 		cb.Condition, cb.Fallback, cb.CheckPeriod, cb.FallbackDuration, cb.RecoveryDuration, cb.OnTripped, cb.OnStandby)
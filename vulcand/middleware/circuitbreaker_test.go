@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"encoding/json"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type CircuitBreakerSuite struct{}
+
+var _ = Suite(&CircuitBreakerSuite{})
+
+func (s *CircuitBreakerSuite) TestSchema(c *C) {
+	mw := NewCircuitBreaker(CircuitBreaker{
+		Condition:   "NetworkErrorRatio() > 0.5",
+		Fallback:    `{"Type": "response", "Action": {"StatusCode": 503, "Body": "Come back later"}}`,
+		CheckPeriod: 100 * time.Millisecond,
+	})
+
+	// When
+	b, err := json.Marshal(mw)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, `{"Type":"cbreaker","Id":"cb1","Priority":1,"Middleware":{"Condition":"NetworkErrorRatio() \u003e 0.5","Fallback":"{\"Type\": \"response\", \"Action\": {\"StatusCode\": 503, \"Body\": \"Come back later\"}}","CheckPeriod":100000000,"FallbackDuration":0,"RecoveryDuration":0,"OnTripped":"","OnStandby":""}}`)
+}
+
+func (s *CircuitBreakerSuite) TestValidate(c *C) {
+	for i, tc := range []struct {
+		spec CircuitBreaker
+		err  string
+	}{{
+		spec: CircuitBreaker{Condition: "NetworkErrorRatio() > 0.5", Fallback: "fallback"},
+	}, {
+		spec: CircuitBreaker{Fallback: "fallback"},
+		err:  "circuit breaker condition is required",
+	}, {
+		spec: CircuitBreaker{Condition: "NetworkErrorRatio() > 0.5"},
+		err:  "circuit breaker fallback is required",
+	}, {
+		spec: CircuitBreaker{Condition: "NetworkErrorRatio() > 0.5", Fallback: "fallback", CheckPeriod: -time.Second},
+		err:  "circuit breaker durations must not be negative",
+	}} {
+		c.Logf("Test case #%d", i)
+		err := tc.spec.Validate()
+		if tc.err == "" {
+			c.Assert(err, IsNil)
+		} else {
+			c.Assert(err, ErrorMatches, tc.err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/mailgun/scroll/vulcand"
@@ -29,6 +30,20 @@ func NewRateLimit(spec RateLimit) vulcand.Middleware {
 	}
 }
 
+// Validate returns an error if the spec would be rejected by vulcand.
+func (rl RateLimit) Validate() error {
+	if rl.Requests <= 0 {
+		return errors.New("rate limit requests must be positive")
+	}
+	if rl.PeriodSeconds <= 0 {
+		return errors.New("rate limit period must be positive")
+	}
+	if rl.Burst < 0 {
+		return errors.New("rate limit burst must not be negative")
+	}
+	return nil
+}
+
 func (rl RateLimit) String() string {
 	return fmt.Sprintf("RateLimit(Variable=%v, Requests=%v, PeriodSeconds=%v, Burst=%v)",
 		rl.Variable, rl.Requests, rl.PeriodSeconds, rl.Burst)
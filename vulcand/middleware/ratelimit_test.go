@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"encoding/json"
+
+	"github.com/mailgun/scroll/vulcand"
+	. "gopkg.in/check.v1"
+)
+
+type RateLimitSuite struct{}
+
+var _ = Suite(&RateLimitSuite{})
+
+func (s *RateLimitSuite) TestSchema(c *C) {
+	mw := NewRateLimit(RateLimit{Variable: "client.ip", Requests: 10, PeriodSeconds: 1, Burst: 5})
+
+	// When
+	b, err := json.Marshal(mw)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, `{"Type":"ratelimit","Id":"rl1","Priority":1,"Middleware":{"Variable":"client.ip","Requests":10,"PeriodSeconds":1,"Burst":5}}`)
+}
+
+func (s *RateLimitSuite) TestValidate(c *C) {
+	for i, tc := range []struct {
+		spec RateLimit
+		err  string
+	}{{
+		spec: RateLimit{Variable: "client.ip", Requests: 1, PeriodSeconds: 1},
+	}, {
+		spec: RateLimit{Variable: "client.ip", Requests: 0, PeriodSeconds: 1},
+		err:  "rate limit requests must be positive",
+	}, {
+		spec: RateLimit{Variable: "client.ip", Requests: 1, PeriodSeconds: -1},
+		err:  "rate limit period must be positive",
+	}, {
+		spec: RateLimit{Variable: "client.ip", Requests: 1, PeriodSeconds: 1, Burst: -1},
+		err:  "rate limit burst must not be negative",
+	}} {
+		c.Logf("Test case #%d", i)
+		err := tc.spec.Validate()
+		if tc.err == "" {
+			c.Assert(err, IsNil)
+		} else {
+			c.Assert(err, ErrorMatches, tc.err)
+		}
+	}
+}
+
+func (s *RateLimitSuite) TestInvalidRejectedByRegistry(c *C) {
+	r, err := vulcand.NewRegistry(vulcand.Config{}, "app1", "192.168.19.2", 8000)
+	c.Assert(err, IsNil)
+
+	// When
+	err = r.AddFrontendWithOptions("mail.gun", "/hello/kitty", []string{"GET"},
+		[]vulcand.Middleware{NewRateLimit(RateLimit{Variable: "host"})}, vulcand.FrontendOptions{})
+
+	// Then
+	c.Assert(err, ErrorMatches, "invalid middleware rl1: rate limit requests must be positive")
+}
@@ -0,0 +1,56 @@
+package vulcand
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type MiddlewareSuite struct{}
+
+var _ = Suite(&MiddlewareSuite{})
+
+func (s *MiddlewareSuite) TestPriorities(c *C) {
+	for i, tc := range []struct {
+		priorities []int
+		expected   []int
+	}{{
+		priorities: []int{},
+		expected:   []int{},
+	}, {
+		priorities: []int{0, 0, 0},
+		expected:   []int{0, 1, 2},
+	}, {
+		priorities: []int{DefaultMiddlewarePriority, DefaultMiddlewarePriority},
+		expected:   []int{0, 1},
+	}, {
+		priorities: []int{DefaultMiddlewarePriority, 0, DefaultMiddlewarePriority},
+		expected:   []int{0, 1, 2},
+	}, {
+		priorities: []int{0, 2, 0},
+		expected:   []int{0, 2, 0},
+	}, {
+		priorities: []int{5, 0, 3},
+		expected:   []int{5, 0, 3},
+	}, {
+		priorities: []int{2, 1, 1},
+		expected:   []int{2, 1, 1},
+	}} {
+		c.Logf("Test case #%d", i)
+		var middlewares []Middleware
+		for _, p := range tc.priorities {
+			middlewares = append(middlewares, Middleware{Priority: p})
+		}
+
+		// When
+		result := withPriorities(middlewares)
+
+		// Then
+		priorities := []int{}
+		for _, mw := range result {
+			priorities = append(priorities, mw.Priority)
+		}
+		c.Assert(priorities, DeepEquals, tc.expected)
+		for j, p := range tc.priorities {
+			c.Assert(middlewares[j].Priority, Equals, p)
+		}
+	}
+}
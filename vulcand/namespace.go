@@ -0,0 +1,25 @@
+package vulcand
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultNamespace is the etcd key prefix used when Config.Namespace is not specified.
+const DefaultNamespace = "/vulcand"
+
+// normalizeNamespace returns the namespace in the "/prefix" form that the etcd key formats expect.
+func normalizeNamespace(namespace string) (string, error) {
+	if namespace == "" {
+		return DefaultNamespace, nil
+	}
+	if strings.ContainsAny(namespace, " \t\r\n") {
+		return "", errors.Errorf("invalid namespace %q: must not contain whitespace", namespace)
+	}
+	normalized := "/" + strings.Trim(namespace, "/")
+	if normalized == "/" || strings.Contains(normalized, "//") {
+		return "", errors.Errorf("invalid namespace %q", namespace)
+	}
+	return normalized, nil
+}
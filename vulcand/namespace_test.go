@@ -0,0 +1,51 @@
+package vulcand
+
+import (
+	"fmt"
+
+	. "gopkg.in/check.v1"
+)
+
+type NamespaceSuite struct{}
+
+var _ = Suite(&NamespaceSuite{})
+
+func (s *NamespaceSuite) TestNormalized(c *C) {
+	for i, tc := range []struct {
+		namespace string
+		key       string
+	}{{
+		namespace: "/vulcand/",
+		key:       "/vulcand/frontends/host.get.path/frontend",
+	}, {
+		namespace: "vulcand",
+		key:       "/vulcand/frontends/host.get.path/frontend",
+	}, {
+		namespace: "",
+		key:       "/vulcand/frontends/host.get.path/frontend",
+	}, {
+		namespace: "/mailgun/vulcand",
+		key:       "/mailgun/vulcand/frontends/host.get.path/frontend",
+	}} {
+		c.Logf("Test case #%d", i)
+
+		// When
+		r, err := NewRegistry(Config{Namespace: tc.namespace}, "app1", "192.168.19.2", 8000)
+
+		// Then
+		c.Assert(err, IsNil)
+		c.Assert(fmt.Sprintf(frontendFmt, r.cfg.Namespace, "host", "get.path"), Equals, tc.key)
+	}
+}
+
+func (s *NamespaceSuite) TestInvalid(c *C) {
+	for i, namespace := range []string{"/", "//", "/vul//cand", "/vul cand"} {
+		c.Logf("Test case #%d", i)
+
+		// When
+		_, err := NewRegistry(Config{Namespace: namespace}, "app1", "192.168.19.2", 8000)
+
+		// Then
+		c.Assert(err, ErrorMatches, "invalid namespace .*")
+	}
+}
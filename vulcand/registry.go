@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +15,8 @@ import (
 
 const (
 	reconnectInterval = time.Second
+	deleteTimeout     = 5 * time.Second
+	frontendDirFmt    = "%s/frontends/%s.%s/"
 	frontendFmt       = "%s/frontends/%s.%s/frontend"
 	middlewareFmt     = "%s/frontends/%s.%s/middlewares/%s"
 	backendFmt        = "%s/backends/%s/backend"
@@ -21,30 +24,88 @@ const (
 )
 
 type Config struct {
+	// etcd key prefix vulcand watches, e.g. "/vulcand". A missing leading slash is added and trailing
+	// slashes are stripped. If not specified, DefaultNamespace is used.
 	Namespace string
-	Etcd      *etcd.Config
 	TTL       time.Duration
+
+	// Etcd client config, NewEtcdConfig helps to build one for clusters that require
+	// authentication and TLS.
+	Etcd *etcd.Config
+
+	// If true, Stop deletes the frontend and middleware keys written by the registry, so that
+	// routing entries are removed immediately on a clean shutdown. Frontends are shared by all
+	// instances of an app, so this should only be enabled if the app runs as a single instance.
+	DeleteFrontendsOnStop bool
+
+	// If provided, the app's server is registered with an https URL and vulcand connects
+	// to it over TLS using these settings.
+	BackendTLS *TLSSettings
+
+	// If provided, overrides vulcand's default settings of the connections to the app's server, e.g.
+	// a longer read timeout for slow endpoints. The settings are shared by all the servers of the backend.
+	BackendHTTP *HTTPSettings
+
+	// Relative weight of the app's server among the servers of the backend, e.g. to send a fraction
+	// of traffic to a canary. If not specified, vulcand's default weight is used.
+	Weight int
+
+	// ID the app's server is registered with among the servers of the backend, e.g. a pod name or an
+	// instance UUID, so that instances sharing a host name and port in different network namespaces
+	// do not overwrite each other's server keys. If not specified, <hostname>_<port> is used.
+	ServerID string
 }
 
 type Registry struct {
 	cfg           Config
-	client        *etcd.Client
 	backendSpec   *backendSpec
+	frontendsMu   sync.Mutex
 	frontendSpecs []*frontendSpec
+	// Guards the client and the lease, it is held while registering so that frontends removed
+	// concurrently are not registered again.
+	mutex         sync.Mutex
+	client        *etcd.Client
+	leaseID       etcd.LeaseID
 	ctx           context.Context
 	cancelFunc    context.CancelFunc
 	wg            sync.WaitGroup
-	leaseID       etcd.LeaseID
 	keepAliveChan <-chan *etcd.LeaseKeepAliveResponse
 	once          *sync.Once
 	done          chan struct{}
 }
 
 func NewRegistry(cfg Config, appName, ip string, port int) (*Registry, error) {
-	backendSpec, err := newBackendSpec(appName, ip, port)
+	namespace, err := normalizeNamespace(cfg.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Namespace = namespace
+
+	var backendSpec *backendSpec
+	if cfg.ServerID != "" {
+		if strings.Contains(cfg.ServerID, "/") {
+			return nil, errors.Errorf("invalid server ID %q: must not contain slashes", cfg.ServerID)
+		}
+		backendSpec, err = newBackendSpecWithID(cfg.ServerID, appName, ip, port)
+	} else {
+		backendSpec, err = newBackendSpec(appName, ip, port)
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create backend")
 	}
+	if cfg.BackendTLS != nil {
+		backendSpec.enableTLS(cfg.BackendTLS)
+	}
+	if cfg.BackendHTTP != nil {
+		if err := cfg.BackendHTTP.validate(); err != nil {
+			return nil, err
+		}
+		backendSpec.HTTP = cfg.BackendHTTP
+	}
+	if cfg.Weight < 0 {
+		return nil, errors.Errorf("invalid weight %d: must not be negative", cfg.Weight)
+	}
+	backendSpec.Weight = cfg.Weight
 
 	c := Registry{
 		cfg:         cfg,
@@ -53,8 +114,89 @@ func NewRegistry(cfg Config, appName, ip string, port int) (*Registry, error) {
 	return &c, nil
 }
 
-func (r *Registry) AddFrontend(host, path string, methods []string, middlewares []Middleware) {
-	r.frontendSpecs = append(r.frontendSpecs, newFrontendSpec(r.backendSpec.AppName, host, path, methods, middlewares))
+// AddFrontend adds a frontend routing requests to the app's backend, it is registered in etcd when the
+// registry is started. To serve the same path under several hosts, add a frontend for each host:
+// frontend keys include the host, so they do not collide.
+//
+// The path must start with a slash, otherwise an error is returned and the frontend is not added.
+func (r *Registry) AddFrontend(host, path string, methods []string, middlewares []Middleware) error {
+	return r.AddFrontendWithOptions(host, path, methods, middlewares, FrontendOptions{})
+}
+
+// AddFrontendWithOptions adds a frontend just like AddFrontend, in addition overriding the default
+// frontend settings with the provided options.
+//
+// Middleware specs that implement a `Validate() error` method are validated and the frontend is not
+// added if any of them is invalid.
+func (r *Registry) AddFrontendWithOptions(host, path string, methods []string, middlewares []Middleware, opts FrontendOptions) error {
+	if path == "" {
+		return errors.New("invalid frontend path: must not be empty")
+	}
+	if !strings.HasPrefix(path, "/") {
+		return errors.Errorf("invalid frontend path %q: must start with a slash", path)
+	}
+	for _, mw := range middlewares {
+		if v, ok := mw.Spec.(validator); ok {
+			if err := v.Validate(); err != nil {
+				return errors.Wrapf(err, "invalid middleware %s", mw.ID)
+			}
+		}
+	}
+	fes := newFrontendSpec(r.backendSpec.AppName, host, path, methods, middlewares)
+	if opts.FailoverPredicate != "" {
+		if strings.TrimSpace(opts.FailoverPredicate) == "" {
+			return errors.New("failover predicate must not be blank")
+		}
+		fes.Options.FailoverPredicate = opts.FailoverPredicate
+	}
+	r.frontendsMu.Lock()
+	r.frontendSpecs = append(r.frontendSpecs, fes)
+	r.frontendsMu.Unlock()
+	return nil
+}
+
+// RemoveFrontend removes the frontend added for the host, path and methods, e.g. to put an endpoint
+// in maintenance without restarting the app. If the registry is started, the frontend and its
+// middlewares are deleted from etcd right away, otherwise the frontend is just not registered.
+func (r *Registry) RemoveFrontend(host, path string, methods []string) error {
+	id := newFrontendSpec(r.backendSpec.AppName, host, path, append([]string(nil), methods...), nil).ID
+	host = strings.ToLower(host)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.frontendsMu.Lock()
+	var removed bool
+	for i, fes := range r.frontendSpecs {
+		if fes.Host == host && fes.ID == id {
+			r.frontendSpecs = append(r.frontendSpecs[:i:i], r.frontendSpecs[i+1:]...)
+			removed = true
+			break
+		}
+	}
+	r.frontendsMu.Unlock()
+	if !removed {
+		return errors.Errorf("frontend not found: host=%s, id=%s", host, id)
+	}
+
+	if r.client == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deleteTimeout)
+	defer cancel()
+	key := fmt.Sprintf(frontendDirFmt, r.cfg.Namespace, host, id)
+	if _, err := r.client.Delete(ctx, key, etcd.WithPrefix()); err != nil {
+		return errors.Wrapf(err, "failed to delete frontend, %s", key)
+	}
+	log.Infof("deleted %s", key)
+	return nil
+}
+
+// frontends returns a snapshot of the frontends to register.
+func (r *Registry) frontends() []*frontendSpec {
+	r.frontendsMu.Lock()
+	defer r.frontendsMu.Unlock()
+	return append([]*frontendSpec(nil), r.frontendSpecs...)
 }
 
 func (r *Registry) createNewLease() error {
@@ -62,7 +204,6 @@ func (r *Registry) createNewLease() error {
 }
 
 func (r *Registry) Start() error {
-	heartBeatTicker := time.Tick(r.cfg.TTL)
 	r.done = make(chan struct{})
 	r.once = &sync.Once{}
 
@@ -73,45 +214,58 @@ func (r *Registry) Start() error {
 
 	const (
 		connected = iota + 1
-		reconnecting
 		alive
 	)
 
+	// The registration loop runs until Stop is called, which revokes the lease so the app's server
+	// is deregistered right away.
+	heartBeatTicker := time.NewTicker(r.cfg.TTL)
 	r.wg.Add(1)
 	go func() {
+		defer r.wg.Done()
+		defer heartBeatTicker.Stop()
 		var status int
 		for {
 			select {
-			case <-heartBeatTicker:
+			case <-heartBeatTicker.C:
 				// If we have NOT received a keep alive response during the ticker interval
 				// assume we should reconnect and register
 				if status != alive {
-					for {
-						if err := r.connectAndRegister(); err != nil {
-							log.Errorf("while reconnecting to etcd: %s", err)
-							status = reconnecting
-							wait := time.After(reconnectInterval)
-							select {
-							case <-r.done:
-								return
-							case <-wait:
-								continue
-							}
-						}
-						break
+					if !r.reconnectAndRegister() {
+						return
 					}
 				}
 				// This just indicates we reconnected, but haven't received a keep alive response
 				status = connected
-			case keep := <-r.keepAliveChan:
+			case keep, ok := <-r.keepAliveChan:
+				if !ok {
+					select {
+					case <-r.done:
+						// The keep alive is cancelled because the registry is being stopped.
+						continue
+					default:
+					}
+					// The lease is lost, e.g. it expired during a network partition, so
+					// re-register with a new lease right away instead of vanishing from routing.
+					log.Infof("keep alive channel closed, lease=%x", r.lease())
+					if !r.reconnectAndRegister() {
+						return
+					}
+					status = connected
+					continue
+				}
 				if keep != nil {
 					log.Debugf("keep alive %+v", keep)
 					status = alive
 				}
 			case <-r.done:
-				_, err := r.client.Revoke(context.Background(), r.leaseID)
+				// Bound the revocation, so that Stop does not hang if etcd is unreachable.
+				ctx, cancel := context.WithTimeout(context.Background(), deleteTimeout)
+				r.mutex.Lock()
+				_, err := r.client.Revoke(ctx, r.leaseID)
+				r.mutex.Unlock()
+				cancel()
 				log.Infof("lease revoked err=(%v)", err)
-				r.wg.Done()
 				return
 			}
 		}
@@ -120,7 +274,28 @@ func (r *Registry) Start() error {
 	return nil
 }
 
+// reconnectAndRegister registers the app with a new lease, retrying until it succeeds. Returns false
+// if the registry is stopped before that.
+func (r *Registry) reconnectAndRegister() bool {
+	for {
+		err := r.connectAndRegister()
+		if err == nil {
+			log.Infof("re-registered with etcd, lease=%x", r.lease())
+			return true
+		}
+		log.Errorf("while reconnecting to etcd: %s", err)
+		select {
+		case <-r.done:
+			return false
+		case <-time.After(reconnectInterval):
+		}
+	}
+}
+
 func (r *Registry) connectAndRegister() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
 	var err error
 
 	// If we are reconnecting, cancel the previous connections
@@ -131,6 +306,9 @@ func (r *Registry) connectAndRegister() error {
 	if r.cfg.Etcd == nil {
 		return errors.New("a valid *etcd.Config{} is required")
 	}
+	if err := validateEtcdConfig(r.cfg.Etcd); err != nil {
+		return err
+	}
 
 	r.client, err = etcd.New(*r.cfg.Etcd)
 	if err != nil {
@@ -162,7 +340,7 @@ func (r *Registry) connectAndRegister() error {
 		return errors.Wrap(err, "failed to write backend spec")
 	}
 
-	for _, fes := range r.frontendSpecs {
+	for _, fes := range r.frontends() {
 		if err := r.registerFrontend(fes); err != nil {
 			r.cancelFunc()
 			return errors.Wrapf(err, "failed to register frontend, %s", fes.ID)
@@ -171,13 +349,29 @@ func (r *Registry) connectAndRegister() error {
 	return nil
 }
 
+// lease returns the ID of the lease the app's server is currently registered with.
+func (r *Registry) lease() etcd.LeaseID {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.leaseID
+}
+
 func (r *Registry) Stop() {
-	if r.cancelFunc != nil {
-		r.cancelFunc()
+	if r.cfg.DeleteFrontendsOnStop {
+		r.mutex.Lock()
+		if r.client != nil {
+			r.deleteFrontends()
+		}
+		r.mutex.Unlock()
 	}
+	// Signal done before cancelling the keep alive, so that its cancellation is not mistaken
+	// for a lost lease.
 	if r.once != nil {
 		r.once.Do(func() { close(r.done) })
 	}
+	if r.cancelFunc != nil {
+		r.cancelFunc()
+	}
 	r.wg.Wait()
 }
 
@@ -201,8 +395,7 @@ func (r *Registry) registerFrontend(fes *frontendSpec) error {
 	if err != nil {
 		return errors.Wrapf(err, "failed to set frontend spec, %s", fesKey)
 	}
-	for i, mw := range fes.Middlewares {
-		mw.Priority = i
+	for _, mw := range withPriorities(fes.Middlewares) {
 		mwKey := fmt.Sprintf(middlewareFmt, r.cfg.Namespace, fes.Host, fes.ID, mw.ID)
 		mwVal, err := json.Marshal(mw)
 		if err != nil {
@@ -215,3 +408,25 @@ func (r *Registry) registerFrontend(fes *frontendSpec) error {
 	}
 	return nil
 }
+
+// deleteFrontends deletes the frontend and middleware keys written by the registry. A failure to
+// delete a key is logged and does not prevent the remaining keys from being deleted.
+func (r *Registry) deleteFrontends() {
+	ctx, cancel := context.WithTimeout(context.Background(), deleteTimeout)
+	defer cancel()
+
+	for _, fes := range r.frontends() {
+		for _, mw := range fes.Middlewares {
+			r.deleteKey(ctx, fmt.Sprintf(middlewareFmt, r.cfg.Namespace, fes.Host, fes.ID, mw.ID))
+		}
+		r.deleteKey(ctx, fmt.Sprintf(frontendFmt, r.cfg.Namespace, fes.Host, fes.ID))
+	}
+}
+
+func (r *Registry) deleteKey(ctx context.Context, key string) {
+	if _, err := r.client.Delete(ctx, key); err != nil {
+		log.Errorf("failed to delete %s: %s", key, err)
+		return
+	}
+	log.Infof("deleted %s", key)
+}
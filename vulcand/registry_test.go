@@ -3,6 +3,7 @@ package vulcand
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
 	"testing"
 	"time"
 
@@ -98,7 +99,7 @@ func (s *RegistrySuite) TestRegisterBackend() {
 	res, err = s.client.Get(s.ctx, testNamespace+"/backends/bar/servers/foo")
 	s.Require().Nil(err)
 	s.Equal(string(res.Kvs[0].Value), `{"URL":"http://example.com:8000"}`)
-	s.Equal(res.Kvs[0].Lease, int64(s.r.leaseID))
+	s.Equal(res.Kvs[0].Lease, int64(s.r.lease()))
 }
 
 func (s *RegistrySuite) TestRegisterFrontend() {
@@ -122,13 +123,65 @@ func (s *RegistrySuite) TestRegisterFrontend() {
 	s.Equal(res.Kvs[0].Lease, int64(0))
 }
 
+func (s *RegistrySuite) TestRegisterFrontendPriorities() {
+	for i, tc := range []struct {
+		priorities []int
+		written    []int
+	}{
+		{[]int{0, 0}, []int{0, 1}},
+		{[]int{DefaultMiddlewarePriority, DefaultMiddlewarePriority}, []int{0, 1}},
+		{[]int{5, 3}, []int{5, 3}},
+	} {
+		path := fmt.Sprintf("/priorities/%d", i)
+		m := []Middleware{{Type: "bar", ID: "first", Spec: "blah", Priority: tc.priorities[0]},
+			{Type: "bar", ID: "second", Spec: "blah", Priority: tc.priorities[1]}}
+		fes := newFrontendSpec("foo", "host", path, []string{"GET"}, m)
+
+		// When
+		err := s.r.registerFrontend(fes)
+
+		// Then
+		s.Require().Nil(err)
+		for j, id := range []string{"first", "second"} {
+			res, err := s.client.Get(s.ctx, fmt.Sprintf(middlewareFmt, testNamespace, "host", fes.ID, id))
+			s.Require().Nil(err)
+			s.Require().Len(res.Kvs, 1)
+			s.Equal(string(res.Kvs[0].Value), fmt.Sprintf(`{"Type":"bar","Id":"%s","Priority":%d,"Middleware":"blah"}`, id, tc.written[j]))
+		}
+	}
+}
+
+func (s *RegistrySuite) TestRegisterFrontendForTwoHosts() {
+	r, err := NewRegistry(s.cfg, "app2", "192.168.19.2", 8001)
+	s.Require().Nil(err)
+	r.AddFrontend("api.example.com", "/path/to/server", []string{"GET"}, nil)
+	r.AddFrontend("api-internal.example.com", "/path/to/server", []string{"GET"}, nil)
+
+	// When
+	err = r.Start()
+	defer r.Stop()
+
+	// Then
+	s.Require().Nil(err)
+
+	res, err := s.client.Get(s.ctx, testNamespace+"/frontends/api.example.com.get.path.to.server/frontend")
+	s.Require().Nil(err)
+	s.Require().Equal(len(res.Kvs), 1)
+	s.Equal(string(res.Kvs[0].Value), `{"Type":"http","BackendId":"app2","Route":"Host(\"api.example.com\") && Method(\"GET\") && Path(\"/path/to/server\")","Settings":{"FailoverPredicate":"(IsNetworkError() || ResponseCode() == 503) && Attempts() <= 2","PassHostHeader":true}}`)
+
+	res, err = s.client.Get(s.ctx, testNamespace+"/frontends/api-internal.example.com.get.path.to.server/frontend")
+	s.Require().Nil(err)
+	s.Require().Equal(len(res.Kvs), 1)
+	s.Equal(string(res.Kvs[0].Value), `{"Type":"http","BackendId":"app2","Route":"Host(\"api-internal.example.com\") && Method(\"GET\") && Path(\"/path/to/server\")","Settings":{"FailoverPredicate":"(IsNetworkError() || ResponseCode() == 503) && Attempts() <= 2","PassHostHeader":true}}`)
+}
+
 func (s *RegistrySuite) TestHeartbeatOnly() {
 	res, err := s.client.Get(s.ctx, testNamespace+"/backends/app1/servers", etcd.WithPrefix())
 	s.Require().Nil(err)
 	s.Equal(len(res.Kvs), 1)
 	serverNode := res.Kvs[0]
 	s.Equal(string(serverNode.Value), `{"URL":"http://192.168.19.2:8000"}`)
-	s.Equal(serverNode.Lease, int64(s.r.leaseID))
+	s.Equal(serverNode.Lease, int64(s.r.lease()))
 
 	// When
 	time.Sleep(3 * time.Second)
@@ -139,7 +192,7 @@ func (s *RegistrySuite) TestHeartbeatOnly() {
 	s.Require().Equal(len(res.Kvs), 1)
 	serverNode = res.Kvs[0]
 	s.Equal(string(serverNode.Value), `{"URL":"http://192.168.19.2:8000"}`)
-	s.Equal(serverNode.Lease, int64(s.r.leaseID))
+	s.Equal(serverNode.Lease, int64(s.r.lease()))
 }
 
 // When registry is stopped the backend server record is immediately removed,
@@ -150,7 +203,7 @@ func (s *RegistrySuite) TestHeartbeatStop() {
 	s.Equal(len(res.Kvs), 1)
 	serverNode := res.Kvs[0]
 	s.Equal(string(serverNode.Value), `{"URL":"http://192.168.19.2:8000"}`)
-	s.Equal(serverNode.Lease, int64(s.r.leaseID))
+	s.Equal(serverNode.Lease, int64(s.r.lease()))
 
 	// When
 	s.r.Stop()
@@ -166,13 +219,155 @@ func (s *RegistrySuite) TestHeartbeatStop() {
 	s.Equal(len(res.Kvs), 0)
 }
 
+// Stop terminates the registration loop, after that the server is no longer re-registered.
+func (s *RegistrySuite) TestStopExitsRegistrationLoop() {
+	stopped := make(chan struct{})
+	go func() {
+		s.r.Stop()
+		close(stopped)
+	}()
+
+	// When
+	var exited bool
+	select {
+	case <-stopped:
+		exited = true
+	case <-time.After(deleteTimeout + time.Second):
+	}
+	s.Require().True(exited, "registration loop did not exit")
+	<-time.After(s.cfg.TTL * 2)
+
+	// Then
+	res, err := s.client.Get(s.ctx, testNamespace+"/backends/app1/servers", etcd.WithPrefix())
+	s.Require().Nil(err)
+	s.Equal(len(res.Kvs), 0)
+}
+
+// When configured to, the registry deletes its frontends and their middlewares on stop.
+func (s *RegistrySuite) TestStopDeletesFrontends() {
+	cfg := s.cfg
+	cfg.DeleteFrontendsOnStop = true
+	r, err := NewRegistry(cfg, "app2", "192.168.19.2", 8001)
+	s.Require().Nil(err)
+	r.AddFrontend("host", "/path/to/server", []string{"GET"}, []Middleware{{Type: "bar", ID: "bazz", Spec: "blah"}})
+	s.Require().Nil(r.Start())
+
+	res, err := s.client.Get(s.ctx, testNamespace+"/frontends/host.get.path.to.server", etcd.WithPrefix())
+	s.Require().Nil(err)
+	s.Equal(len(res.Kvs), 2)
+
+	// When
+	r.Stop()
+
+	// Then
+	res, err = s.client.Get(s.ctx, testNamespace+"/frontends/host.get.path.to.server", etcd.WithPrefix())
+	s.Require().Nil(err)
+	s.Equal(len(res.Kvs), 0)
+
+	res, err = s.client.Get(s.ctx, testNamespace+"/backends/app2/backend")
+	s.Require().Nil(err)
+	s.Equal(string(res.Kvs[0].Value), `{"Type":"http"}`)
+}
+
+// Instances of an app with distinct server IDs register distinct servers, even on the same address.
+func (s *RegistrySuite) TestServerID() {
+	var registries []*Registry
+	for _, id := range []string{"pod-1", "pod-2"} {
+		cfg := s.cfg
+		cfg.ServerID = id
+		r, err := NewRegistry(cfg, "app2", "192.168.19.2", 8001)
+		s.Require().Nil(err)
+		s.Require().Nil(r.Start())
+		registries = append(registries, r)
+	}
+	defer func() {
+		for _, r := range registries {
+			r.Stop()
+		}
+	}()
+
+	res, err := s.client.Get(s.ctx, testNamespace+"/backends/app2/servers", etcd.WithPrefix())
+	s.Require().Nil(err)
+	s.Require().Equal(len(res.Kvs), 2)
+	s.Equal(string(res.Kvs[0].Key), testNamespace+"/backends/app2/servers/pod-1")
+	s.Equal(string(res.Kvs[1].Key), testNamespace+"/backends/app2/servers/pod-2")
+}
+
+// A removed frontend and its middlewares are deleted, the other frontends are left intact.
+func (s *RegistrySuite) TestRemoveFrontend() {
+	r, err := NewRegistry(s.cfg, "app2", "192.168.19.2", 8001)
+	s.Require().Nil(err)
+	r.AddFrontend("host", "/path/to/server", []string{"GET"}, []Middleware{{Type: "bar", ID: "bazz", Spec: "blah"}})
+	r.AddFrontend("host", "/path/to/server2", []string{"GET"}, []Middleware{{Type: "bar", ID: "bazz", Spec: "blah"}})
+	s.Require().Nil(r.Start())
+	defer r.Stop()
+
+	// When
+	err = r.RemoveFrontend("host", "/path/to/server", []string{"GET"})
+
+	// Then
+	s.Require().Nil(err)
+	res, err := s.client.Get(s.ctx, testNamespace+"/frontends/host.get.path.to.server/", etcd.WithPrefix())
+	s.Require().Nil(err)
+	s.Equal(len(res.Kvs), 0)
+
+	res, err = s.client.Get(s.ctx, testNamespace+"/frontends/host.get.path.to.server2/", etcd.WithPrefix())
+	s.Require().Nil(err)
+	s.Equal(len(res.Kvs), 2)
+}
+
+// Frontends removed while the registry re-registers, e.g. after the lease is lost, are not registered
+// again; run with -race.
+func (s *RegistrySuite) TestRemoveFrontendDuringReconnect() {
+	r, err := NewRegistry(s.cfg, "app2", "192.168.19.2", 8001)
+	s.Require().Nil(err)
+	r.AddFrontend("host", "/path/to/server", []string{"GET"}, []Middleware{{Type: "bar", ID: "bazz", Spec: "blah"}})
+	s.Require().Nil(r.Start())
+	defer r.Stop()
+	prevLease := r.lease()
+	_, err = s.client.Revoke(s.ctx, prevLease)
+	s.Require().Nil(err)
+
+	// When
+	err = r.RemoveFrontend("host", "/path/to/server", []string{"GET"})
+
+	// Then
+	s.Require().Nil(err)
+	// Give time to re-register
+	<-time.After(time.Millisecond * 500)
+	s.NotEqual(r.lease(), prevLease)
+	res, err := s.client.Get(s.ctx, testNamespace+"/frontends/host.get.path.to.server/", etcd.WithPrefix())
+	s.Require().Nil(err)
+	s.Equal(len(res.Kvs), 0)
+}
+
+// When the lease is lost the keep alive channel is closed and the registry re-registers with a new lease.
+func (s *RegistrySuite) TestReregisterOnLeaseLoss() {
+	prevLease := s.r.lease()
+
+	// When
+	_, err := s.client.Revoke(s.ctx, prevLease)
+	s.Require().Nil(err)
+
+	// Give time to re-register
+	<-time.After(time.Millisecond * 500)
+
+	// Then
+	res, err := s.client.Get(s.ctx, testNamespace+"/backends/app1/servers", etcd.WithPrefix())
+	s.Require().Nil(err)
+	s.Require().Equal(len(res.Kvs), 1)
+	s.Equal(string(res.Kvs[0].Value), `{"URL":"http://192.168.19.2:8000"}`)
+	s.Equal(res.Kvs[0].Lease, int64(s.r.lease()))
+	s.NotEqual(s.r.lease(), prevLease)
+}
+
 func (s *RegistrySuite) TestHeartbeatNetworkTimeout() {
 	res, err := s.client.Get(s.ctx, testNamespace+"/backends/app1/servers", etcd.WithPrefix())
 	s.Require().Nil(err)
 	s.Require().Equal(len(res.Kvs), 1)
 	s.Equal(string(res.Kvs[0].Value), `{"URL":"http://192.168.19.2:8000"}`)
-	s.Equal(res.Kvs[0].Lease, int64(s.r.leaseID))
-	prevLease := s.r.leaseID
+	s.Equal(res.Kvs[0].Lease, int64(s.r.lease()))
+	prevLease := s.r.lease()
 
 	// When
 	s.proxy.Disable()
@@ -190,6 +385,6 @@ func (s *RegistrySuite) TestHeartbeatNetworkTimeout() {
 	s.Require().Nil(err)
 	s.Require().Equal(len(res.Kvs), 1)
 	s.Equal(string(res.Kvs[0].Value), `{"URL":"http://192.168.19.2:8000"}`)
-	s.Equal(res.Kvs[0].Lease, int64(s.r.leaseID))
-	s.NotEqual(s.r.leaseID, prevLease)
+	s.Equal(res.Kvs[0].Lease, int64(s.r.lease()))
+	s.NotEqual(s.r.lease(), prevLease)
 }